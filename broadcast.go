@@ -0,0 +1,122 @@
+package client
+
+import "sync"
+
+// broadcast fans Updates out from one subscription to any number of listeners.
+type broadcast struct {
+	mu sync.Mutex
+
+	// The current listeners
+	listeners map[*listener]struct{}
+
+	// The last value sent, which new listeners receive when they join
+	last *Update
+
+	// The buffer size of each listener's channel
+	buffer int
+
+	// True once the broadcast has ended
+	closed bool
+
+	// Closed when the source subscription is canceled, which abandons sends to listeners that aren't reading
+	canceled <-chan struct{}
+}
+
+// listener is a single recipient of a broadcast.
+type listener struct {
+	// Held while sending on ch, so ch isn't closed mid-send
+	mu sync.Mutex
+
+	ch     chan Update
+	done   chan struct{}
+	once   sync.Once
+	closed bool
+}
+
+// newBroadcast creates a broadcast whose listeners' channels have the given buffer size, which must be at least 1.
+// Sends are abandoned once canceled is closed, so a listener which stops reading can't hold up the others.
+func newBroadcast(buffer int, canceled <-chan struct{}) *broadcast {
+	return &broadcast{listeners: map[*listener]struct{}{}, buffer: buffer, canceled: canceled}
+}
+
+// add registers a new listener, which immediately receives the last value sent, if any. Listeners added after close
+// start out closed.
+func (b *broadcast) add() *listener {
+	l := &listener{ch: make(chan Update, b.buffer), done: make(chan struct{})}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		// the source already stopped, so there is nothing left to receive
+		l.stop()
+		return l
+	}
+	b.listeners[l] = struct{}{}
+	if b.last != nil {
+		// the channel is new and buffered, so this can't block
+		l.ch <- *b.last
+	}
+	return l
+}
+
+// remove unregisters a listener and closes its channel. Removing a listener more than once does nothing.
+func (b *broadcast) remove(l *listener) {
+	b.mu.Lock()
+	delete(b.listeners, l)
+	b.mu.Unlock()
+	l.stop()
+}
+
+// send delivers u to every current listener, waiting for each to have room unless it is removed first or the source
+// is canceled.
+func (b *broadcast) send(u Update) {
+	b.mu.Lock()
+	if u.Error == nil && !u.Unchanged {
+		b.last = &u
+	}
+	listeners := make([]*listener, 0, len(b.listeners))
+	for l := range b.listeners {
+		listeners = append(listeners, l)
+	}
+	b.mu.Unlock()
+
+	for _, l := range listeners {
+		l.send(u, b.canceled)
+	}
+}
+
+// close removes all listeners, closing their channels.
+func (b *broadcast) close() {
+	b.mu.Lock()
+	listeners := b.listeners
+	b.listeners = map[*listener]struct{}{}
+	b.closed = true
+	b.mu.Unlock()
+	for l := range listeners {
+		l.stop()
+	}
+}
+
+// send delivers u to the listener unless it stops or canceled is closed first.
+func (l *listener) send(u Update, canceled <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return
+	}
+	select {
+	case l.ch <- u:
+	case <-l.done:
+	case <-canceled:
+	}
+}
+
+// stop abandons any send in progress and closes the listener's channel.
+func (l *listener) stop() {
+	l.once.Do(func() {
+		close(l.done)
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.closed = true
+		close(l.ch)
+	})
+}
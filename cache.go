@@ -0,0 +1,72 @@
+package client
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// cacheEntry is the contents of a CacheFile.
+type cacheEntry struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Value        []byte `json:"value"`
+}
+
+// loadCache reads the value and cache validators saved in CacheFile. It returns False if there is no CacheFile, or
+// if it is missing or corrupt.
+func (c *Client) loadCache() ([]byte, version, bool) {
+	if c.CacheFile == "" {
+		return nil, version{}, false
+	}
+	f, err := os.Open(c.CacheFile)
+	if err != nil {
+		return nil, version{}, false
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, version{}, false
+	}
+	defer gz.Close()
+	var entry cacheEntry
+	if err := json.NewDecoder(gz).Decode(&entry); err != nil || entry.Value == nil {
+		return nil, version{}, false
+	}
+	return entry.Value, version{etag: entry.ETag, lastModified: entry.LastModified}, true
+}
+
+// saveCache writes a value and its cache validators to CacheFile, if set. The file is replaced atomically, so a crash
+// mid-write can't corrupt it.
+func (c *Client) saveCache(data []byte, ver version) {
+	if c.CacheFile == "" {
+		return
+	}
+	// the cache is only an optimization, so failing to write it isn't worth interrupting polling
+	_ = writeCache(c.CacheFile, cacheEntry{ETag: ver.etag, LastModified: ver.lastModified, Value: data})
+}
+
+// writeCache writes entry to path by way of a temporary file in the same directory.
+func writeCache(path string, entry cacheEntry) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	gz := gzip.NewWriter(tmp)
+	if err := json.NewEncoder(gz).Encode(entry); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -13,30 +13,50 @@
 //         log.Panic(err)
 //     }
 //
-//     for {
-//         update := <-updates
-//         if update.err != nil {
+//     for update := range updates {
+//         if update.Error != nil {
 //             // Failure to fetch an update isn't all that bad.
 //             // Just use the last cached value for now.
-//             log.Println(err)
-//             break
+//             log.Println(update.Error)
+//             continue
 //         }
 //
 //         // Blob values are provided as []byte,
 //         // which you can pass to your parser of choice
 //         updateMyAppConfig(update.Value)
 //     }
+//
+// A subscription polls on its own goroutine until it is canceled, even if nothing reads its channel anymore. To avoid
+// leaking the goroutine, call Cancel when you're done with a subscription, or use SubscribeContext and cancel its
+// context. Tests can check ActiveSubscriptions after cleaning up to detect leaked subscriptions.
+//
+// The Viteset API serves blobs over plain HTTP and has no streaming, push, or WebSocket endpoint, so polling is the
+// only way to watch a blob, and a change can take up to Interval to arrive. When you know a blob just changed, such as
+// right after calling Set, call Refresh to fetch it immediately.
 package client
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math/rand"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
 )
 
-// The package version.
+// The package version. This is the single source of truth for Version and the User-Agent.
 const VERSION = "1.0.0"
 
 // The default Viteset host to fetch blobs from.
@@ -45,29 +65,406 @@ const DEFAULT_HOST = "https://api.viteset.com"
 // The default interval for polling for blob updates.
 const DEFAULT_INTERVAL = 15 * time.Second
 
+// The shortest allowed interval for polling for blob updates. Polling more often greatly impacts load on Viteset
+// servers.
+const MIN_INTERVAL = 15 * time.Second
+
+// The default time limit for a single fetch of a blob.
+const DEFAULT_TIMEOUT = 10 * time.Second
+
+// The default number of Updates which can queue in a subscription's channel.
+const DEFAULT_CHANNEL_BUFFER = 1
+
+// The default largest response body a Client will read, in bytes.
+const DEFAULT_MAX_BODY_SIZE = 10 * 1024 * 1024
+
+// The default longest delay between polls while fetches are failing.
+const DEFAULT_MAX_BACKOFF = 5 * time.Minute
+
+// The default factor the delay between polls grows by after each consecutive failed fetch.
+const DEFAULT_BACKOFF_MULTIPLIER = 2.0
+
+// The default delay before the first quick retry of a subscription's initial fetch.
+const DEFAULT_INITIAL_RETRY_DELAY = 1 * time.Second
+
+// The default delay before the first retry of a failed fetch within a poll.
+const DEFAULT_IN_TICK_RETRY_DELAY = 100 * time.Millisecond
+
+// The default time limit for establishing a connection to the server.
+const DEFAULT_DIAL_TIMEOUT = 5 * time.Second
+
+// The default number of blobs MultiClient.GetMany fetches at once.
+const DEFAULT_MAX_CONCURRENCY = 4
+
+// The default time a subscription's circuit breaker stays open before the Client tries fetching again.
+const DEFAULT_BREAKER_COOLDOWN = 5 * time.Minute
+
 var userAgent = fmt.Sprintf("Viteset-Client-Go/%s", VERSION)
 
+// The number of polling goroutines which are currently running, across all Clients.
+var activeSubscriptions int64
+
+// The default HTTP status codes which end a subscription.
+var defaultFatalStatusCodes = []int{http.StatusUnauthorized, http.StatusForbidden}
+
+// The default HTTP status codes whose responses carry the value of the blob.
+var defaultAcceptStatus = []int{http.StatusOK}
+
+// The HTTP client used by Clients which don't specify their own. It is created once and shared so polls reuse TCP
+// connections and TLS sessions, and has its own transport so changes to http.DefaultTransport don't affect it.
+var defaultHTTPClient = &http.Client{Transport: baseTransport()}
+
 // Client accesses a blob from Viteset and sends updates via a channel.
-// The Client uses ETags to minimize data received when the blob hasn't changed since the last poll.
+// The Client uses ETags (or Last-Modified times, if the server provides no ETag) to minimize data received when the
+// blob hasn't changed since the last poll.
 type Client struct {
 	// The secret for a client with access to the specified blob
 	Secret string
 
-	// The name of the blob to subscribe to
+	// The name of the blob to subscribe to. The name is escaped in request URLs, so it may contain slashes, spaces, or
+	// query characters, but not control characters.
 	Blob string
 
 	// Optional: The update polling interval. Default is 15 seconds.
-	// Please don't reduce this below 15 seconds: this greatly impacts load on Viteset servers.
+	// Intervals below MIN_INTERVAL (15 seconds) are rejected: polling more often greatly impacts load on Viteset servers.
 	Interval time.Duration
 
 	// Optional: The hostname of the Viteset API. Default is Viteset production servers.
+	// A Host without a scheme, like "api.viteset.com", uses https, and trailing slashes are removed. Subscribe returns
+	// an error if Host isn't a valid http or https URL.
 	Host string
 
+	// Optional: Hostnames of the Viteset API to fail over between, in order of preference. When set, Host is ignored.
+	// Fetches go to the current healthy host and fall over to the next one on a network error or 5xx response. After
+	// the first host fails, the Client goes back to it once a backoff delay has passed. Default is just Host.
+	Hosts []string
+
+	// Optional: The time limit for each individual fetch, including reading the response body. Default is 10 seconds.
+	Timeout time.Duration
+
+	// Optional: The largest random adjustment added to or subtracted from each delay between polls, which spreads out
+	// polls from many Clients started at the same time. Jitter must be less than Interval, and jittered delays are never
+	// shorter than MIN_INTERVAL. Default is 0, which polls exactly on the interval.
+	Jitter time.Duration
+
+	// Optional: How long a changed value must stay the same before it is sent, for blobs which change in bursts.
+	// After seeing a change, the Client checks again after this window instead of after Interval, and only sends the
+	// value once a check finds it unchanged; another change restarts the window. The initial value is never delayed.
+	// If a check fails, the Client backs off as usual. Windows below MIN_INTERVAL are rejected, like intervals.
+	// Default is 0, which sends every change immediately.
+	DebounceWindow time.Duration
+
+	// Optional: The number of Updates which can queue in the channel while the consumer is busy. Once the buffer is
+	// full, the Client waits for the consumer to read an Update before polling again. Default is 1.
+	ChannelBuffer int
+
+	// Optional: If true, when the channel is full, the oldest queued Update is discarded to make room for the newest
+	// instead of waiting for the consumer, so the consumer always sees the latest value. Error Updates may be discarded
+	// too. Default is false.
+	DropStale bool
+
+	// Optional: The longest delay between polls while fetches are failing. Default is 5 minutes.
+	MaxBackoff time.Duration
+
+	// Optional: The factor the delay between polls grows by after each consecutive failed fetch, up to MaxBackoff.
+	// The delay returns to Interval once a fetch succeeds. Set this to 1 to disable backoff. Must be at least 1.
+	// Default is 2.
+	BackoffMultiplier float64
+
+	// Optional: How long a subscription waits before its first fetch, such as to let the rest of the application warm
+	// up, or to stagger a fleet's fetches at boot. Subscribe still returns the channel right away, and a Refresh ends
+	// the wait early. Default is 0, which fetches immediately.
+	StartDelay time.Duration
+
+	// Optional: The number of times to quickly retry a subscription's first fetch before waiting a full Interval,
+	// so a brief network hiccup at startup doesn't delay the initial value. Errors from these attempts aren't sent;
+	// the Client sends the last error only if every attempt fails. Default is 0, which doesn't retry quickly.
+	InitialRetries int
+
+	// Optional: The delay before the first quick retry of the initial fetch, which doubles after each attempt, up to
+	// Interval. A longer Retry-After from the server takes precedence. Default is 1 second, so 3 InitialRetries happen
+	// after 1, 2, and 4 seconds.
+	InitialRetryDelay time.Duration
+
+	// Optional: The number of times to retry a fetch which fails with a NetworkError or ServerError within the same
+	// poll, so a single dropped connection doesn't cost a whole Interval of staleness. The Client sends the error only
+	// if every retry fails. Retries, including their fetches, only happen within a quarter of the Interval from the
+	// start of the poll, and a retry still running at the end of that window is abandoned. Errors with a Retry-After
+	// aren't retried. Default is 0, which doesn't retry within a poll.
+	InTickRetries int
+
+	// Optional: The delay before the first retry within a poll, which doubles after each attempt. Each delay is
+	// jittered randomly between half and one and a half times its length. Default is 100 milliseconds.
+	InTickRetryDelay time.Duration
+
+	// Optional: The number of consecutive failed fetches which open a subscription's circuit breaker. While the
+	// breaker is open, the Client makes a single attempt every BreakerCooldown, and sends no Updates for the errors
+	// after the first, which carries a CircuitOpenError. The breaker closes and polling returns to normal once a fetch
+	// succeeds. Default is 0, which disables the breaker.
+	MaxConsecutiveErrors int
+
+	// Optional: How long the circuit breaker stays open before the Client tries fetching again. Default is 5 minutes.
+	BreakerCooldown time.Duration
+
+	// Optional: The HTTP status codes which indicate the subscription can never succeed, such as an invalid Secret.
+	// When a fetch fails with one of these, the Client sends a final Update with the error, then cancels the
+	// subscription and closes the channel. Set this to an empty slice to retry on every status. Default is 401 and 403.
+	FatalStatusCodes []int
+
+	// Optional: The HTTP status codes whose responses carry the value of the blob, such as 203 from a CDN which
+	// rewrites status codes. Any other status except 304 is an error. Default is just 200.
+	AcceptStatus []int
+
+	// Optional: The media type the server must respond with, such as "application/json". When set, a fetch which
+	// returns a different Content-Type fails with a ContentTypeError instead of delivering the value, which catches
+	// misconfigured proxies and captive portals serving HTML. Default is to accept any Content-Type.
+	ExpectContentType string
+
+	// Optional: The largest response body the Client will read, in bytes. Larger responses fail with a
+	// BodyTooLargeError rather than exhausting memory. Default is 10 MB.
+	MaxBodySize int64
+
+	// Optional: If true, fetches never send If-None-Match or If-Modified-Since, so the server always returns the full
+	// body. This is a diagnostic escape hatch: since the Client can't tell that the blob is unchanged, every poll sends
+	// an Update, and a 304 is treated as an error. Default is false, which uses conditional requests.
+	DisableConditional bool
+
+	// Optional: If true, a fetch which returns a new version of the blob with the same bytes as the last value is
+	// treated as unchanged and sends no Update, for servers which change the ETag on no-op saves. The new ETag is
+	// still used for later conditional requests. Default is false, which sends an Update for every new version.
+	CompareBytes bool
+
+	// Optional: If true, a 304 in response to a fetch which sent no ETag or Last-Modified time is an error, with a
+	// StatusError, rather than being ignored. Such a response means a cache or proxy is misbehaving, and otherwise
+	// leaves a new subscription with no value and no error. Default is false.
+	StrictCaching bool
+
+	// Optional: Decides whether a new version of the blob is the same as the last value, in place of comparing bytes,
+	// such as by comparing normalized JSON. Setting this implies CompareBytes. Default is nil, which uses byte
+	// equality when CompareBytes is set.
+	EqualFunc func(old, new []byte) bool
+
+	// Optional: Checks each fetched value before the Client accepts it, such as by decoding it or checking it against
+	// a JSON schema. When it returns an error, the fetch counts as failed: the Client sends an Update with a
+	// ValidationError and keeps the last valid value, so a bad push doesn't reach consumers. Default is nil, which
+	// accepts every value.
+	Validate func(value []byte) error
+
+	// Optional: If true, an empty value is rejected like one which fails Validate, with a ValidationError wrapping
+	// ErrEmptyValue, so a server which transiently returns an empty body can't wipe out a working config. Default is
+	// false, which accepts empty values.
+	RejectEmpty bool
+
+	// Optional: If true, Delete succeeds when the blob doesn't exist instead of returning a NotFoundError.
+	// Default is false.
+	DeleteIgnoreNotFound bool
+
+	// Optional: If true, each poll which finds the blob unchanged sends an Update with Unchanged set, so consumers can
+	// tell that the Client is still polling successfully. Default is false, which sends nothing for unchanged blobs.
+	Heartbeat bool
+
+	// Optional: Called with each Update instead of sending it on the channel, for event-driven code which doesn't want
+	// to run its own receive loop. The channel returned by Subscribe still closes when the subscription ends.
+	//
+	// OnUpdate runs on the polling goroutine, so polling waits for it to return. It may call Cancel. If it panics, the
+	// Client recovers, calls it again with an Update carrying a PanicError, and keeps polling.
+	OnUpdate func(update Update)
+
+	// Optional: Called with the error of each Update which reports one, in addition to sending the Update. This is
+	// how errors are observed when using Watch. Like OnUpdate, it runs on the polling goroutine. If it panics, the
+	// Client recovers, sends a further Update carrying a PanicError without calling OnError for it, and keeps polling.
+	OnError func(err error)
+
+	// Optional: Receives a report after each fetch, for recording metrics. Default is no reporting.
+	Metrics Metrics
+
+	// Optional: Receives a structured entry after each fetch, for logging. NewTextLogger writes plain text and
+	// NewJSONLogger writes JSON. Default is no logging.
+	Logger Logger
+
+	// Optional: Extra headers to send with every request, such as API gateway keys or tenant IDs. The headers the
+	// Client manages itself take precedence: User-Agent, the auth header, Accept-Encoding, If-None-Match, and
+	// If-Modified-Since are never taken from Headers. Default is no extra headers.
+	Headers http.Header
+
+	// Optional: Your application's name and version, such as "myservice/2.3.1", which is appended to the User-Agent
+	// so server logs can attribute traffic to it. The library's own identifier is always kept. Default is "".
+	UserAgentSuffix string
+
+	// Optional: The name of the header which carries the Secret, for gateways which expect something like
+	// X-Api-Key. Default is Authorization.
+	AuthHeader string
+
+	// Optional: The scheme which precedes the Secret in the auth header. Default is Bearer when AuthHeader is unset,
+	// and no scheme (just the Secret) otherwise.
+	AuthScheme string
+
+	// Optional: Adds credentials to each request in place of AuthHeader and AuthScheme, for gateways with other
+	// authentication needs. Default is to send the Secret as described above.
+	Authorize func(req *http.Request)
+
+	// Optional: Called around each fetch, for tracing. See the vitesetotel package for OpenTelemetry support.
+	// Default is no tracing.
+	Tracer Tracer
+
+	// Optional: Called with each fetch's response, before the Client reads it, to inspect headers such as request IDs
+	// or rate limits. The response passed has an empty body and its own copy of the headers, so the inspector can't
+	// interfere with the Client. Default is nil.
+	OnResponse func(*http.Response)
+
+	// Optional: The source of time for polling schedules, timestamps, and backoff, such as a fake clock in tests.
+	// Default is the system clock.
+	Clock Clock
+
+	// Optional: The source of randomness for Jitter, such as a fixed seed in tests. Sources aren't safe for concurrent
+	// use, so don't share one between Clients. Default is a source seeded from the current time.
+	RandSource rand.Source
+
+	// Optional: The HTTP client used to make requests. Set this to configure timeouts, proxies, TLS settings, or
+	// connection pooling. This takes precedence over the transport settings below, such as TLSConfig. Default is a
+	// client shared by all Clients.
+	HTTPClient *http.Client
+
+	// Optional: The TLS configuration for connecting to the server, such as a client certificate for mutual TLS or a
+	// pool of custom root CAs. Setting this gives the Client an HTTP client of its own, created when the Client is
+	// first used, so later changes have no effect. Ignored if HTTPClient is set. Default is Go's TLS defaults.
+	TLSConfig *tls.Config
+
+	// Optional: The time limit for establishing a connection to the server, including DNS resolution, so an
+	// unreachable server fails fast even when Timeout is generous. Setting this gives the Client an HTTP client of its
+	// own, like TLSConfig. Ignored if HTTPClient is set. Default is 5 seconds.
+	DialTimeout time.Duration
+
+	// Optional: If true, the Client only speaks HTTP/1.1, for proxies which mishandle HTTP/2. Setting this gives the
+	// Client an HTTP client of its own, like TLSConfig. Ignored if HTTPClient is set. Default is false, which uses
+	// HTTP/2 when the server supports it over TLS.
+	DisableHTTP2 bool
+
+	// Optional: If true, each request opens a fresh connection rather than reusing an idle one, for networks where
+	// NAT devices silently drop idle connections between polls and cause occasional failed fetches. This trades a
+	// new TCP and TLS handshake per poll for reliability. Setting this gives the Client an HTTP client of its own,
+	// like TLSConfig. Ignored if HTTPClient is set. Default is false, which reuses connections.
+	DisableKeepAlives bool
+
+	// Optional: Opens connections to the server in place of TCP, such as to reach a local agent over a unix domain
+	// socket or an in-process server over a pipe. DialTimeout doesn't apply. For a unix socket, set Host to any
+	// http:// URL and dial the socket regardless of the address:
+	//
+	//     c.Host = "http://viteset"
+	//     c.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+	//         var d net.Dialer
+	//         return d.DialContext(ctx, "unix", "/run/viteset.sock")
+	//     }
+	//
+	// Setting this gives the Client an HTTP client of its own, like TLSConfig. Ignored if HTTPClient is set. Default
+	// is nil, which dials TCP.
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// Optional: The ETag of a previously fetched value of the blob, such as one saved with ETag before the process
+	// restarted. If the blob hasn't changed, the first poll gets a 304 rather than downloading the whole blob. Use this
+	// with InitialValue.
+	InitialETag string
+
+	// Optional: The previously fetched value of the blob which InitialETag identifies. If the first poll gets a 304,
+	// Subscribe still sends one Update carrying this value, so subscribers always receive an initial value. Later 304s
+	// send nothing unless Heartbeat is set.
+	InitialValue []byte
+
+	// Optional: A value to fall back on if the first fetch of a subscription fails, so the app can start while Viteset
+	// is unreachable. After the error Update, the Client sends an Update carrying this value with Fallback set, then
+	// keeps polling and sends the real value once it arrives. Default is nil, which sends no fallback.
+	DefaultValue []byte
+
+	// Optional: A file to persist the last value and its ETag in, compressed, for fast restarts. Each new value is
+	// written to the file, and Subscribe loads it to seed the cache, taking precedence over InitialValue, so the first
+	// poll can get a 304. A missing or corrupt file is ignored. Default is "", which doesn't persist anything.
+	CacheFile string
+
+	// Guards the fields below, and Interval while subscribed
+	mu sync.Mutex
+
 	// The last-retrieved value for the blob
 	last []byte
 
-	// The ticker that polls for updates to the blob at an interval
-	ticker *time.Ticker
+	// True once last holds a value, either fetched or from InitialValue
+	hasValue bool
+
+	// The cache validators for the last-retrieved value
+	ver version
+
+	// When the last successful fetch completed
+	lastSuccess time.Time
+
+	// Counters describing the fetches made so far
+	stats Stats
+
+	// Stops the polling goroutine, which then closes the Update channel. Set to nil once the subscription is canceled.
+	cancel context.CancelFunc
+
+	// Closed when the subscription is canceled, by Cancel or its context, but not when it ends on its own
+	canceled <-chan struct{}
+
+	// Closed by the polling goroutine once it has stopped
+	done chan struct{}
+
+	// The channel of the latest subscription, which Drain empties
+	updates chan Update
+
+	// Fans Updates out to the channels from AddListener, while the subscription they started is running
+	listeners *broadcast
+
+	// Held while AddListener starts a subscription for its listeners
+	listenMu sync.Mutex
+
+	// While the subscription is paused, a channel which Resume closes; otherwise nil
+	resume chan struct{}
+
+	// Signals the polling goroutine to fetch immediately. Buffered so that concurrent requests coalesce.
+	refresh chan struct{}
+
+	// Asks the polling goroutine to fetch immediately and reply with the result
+	calls chan refreshCall
+
+	// Signals the polling goroutine that Interval changed. Buffered so that concurrent changes coalesce.
+	reschedule chan struct{}
+
+	// Extra delay before the first scheduled poll after the initial fetch, used to spread out a MultiClient's polls
+	stagger time.Duration
+
+	// The context for requests made by methods which don't take one, set by WithContext
+	baseCtx context.Context
+
+	// The HTTP client built from the transport settings, if any are set
+	ownHTTPClient *http.Client
+
+	// Which of Hosts is currently healthy, guarded by mu
+	hostIndex int
+
+	// The consecutive failures of the first of Hosts, and when to try it again, guarded by mu
+	primaryFailures int
+	primaryRetryAt  time.Time
+}
+
+// Subscriber is the stable surface for watching a blob, which Client and MultiClient satisfy. Depend on Subscriber
+// rather than *Client to substitute a fake in tests.
+type Subscriber interface {
+	// Subscribe starts watching the blob for changes. See Client.Subscribe.
+	Subscribe() (<-chan Update, error)
+
+	// Cancel cancels the subscription. See Client.Cancel.
+	Cancel()
+
+	// Active returns True if the subscription is active. See Client.Active.
+	Active() bool
+}
+
+var _ Subscriber = (*Client)(nil)
+
+// version holds the cache validators the server provided for a blob value, which are sent with the next fetch so the
+// server can respond 304 Not Modified if the blob hasn't changed. ETags are preferred over modification times.
+type version struct {
+	etag         string
+	lastModified string
 }
 
 // Update contains either a blob's latest value, or an error that occurred during the last fetch. You must check
@@ -78,6 +475,40 @@ type Client struct {
 type Update struct {
 	Value []byte
 	Error error
+
+	// The HTTP status code of a failed fetch, so handlers can tell a 404 (blob deleted) from a 503 (temporary outage).
+	// This is 0 for successful fetches and for errors where no response was received, such as network failures.
+	StatusCode int
+
+	// The name of the blob this Update is for, which distinguishes updates from a MultiClient
+	Blob string
+
+	// The server's ETag for Value, which identifies this version of the blob. This is "" for errors, or if the server
+	// didn't provide one.
+	ETag string
+
+	// When the fetch which produced this Update completed, whether it succeeded or failed
+	FetchedAt time.Time
+
+	// How long the fetch which produced this Update took, from sending the request to reading the whole response
+	// body, including any failover between Hosts. With InTickRetries, this is the duration of the last attempt.
+	Duration time.Duration
+
+	// True if this is a heartbeat confirming that the blob is unchanged, sent only when the Client's Heartbeat is set.
+	// Value holds the current, unchanged value.
+	Unchanged bool
+
+	// True if Value is the Client's DefaultValue, sent because the first fetch failed, rather than a value from the
+	// server
+	Fallback bool
+
+	// True if this is the first value sent by the subscription, which consumers may handle differently from later
+	// changes. This is False for errors and for all Updates after the first value, including after a fallback.
+	Initial bool
+
+	// True if this is the first Update after one or more failed fetches, so consumers can clear alerts. If the blob
+	// didn't change during the outage, this Update has Unchanged set and carries the current value.
+	Recovered bool
 }
 
 // Subscribe starts watching the blob for changes. It returns a channel and an error.
@@ -89,89 +520,1054 @@ type Update struct {
 //
 // On a successful subscription, the Client will always send the initial value of the blob via the channel.
 func (c *Client) Subscribe() (<-chan Update, error) {
+	return c.SubscribeContext(c.baseContext())
+}
+
+// SubscribeContext works like Subscribe, but ties the subscription to the lifetime of ctx.
+//
+// When ctx is canceled, the Client stops polling and closes the Update channel, so `for range` loops over the channel
+// terminate cleanly.
+func (c *Client) SubscribeContext(ctx context.Context) (<-chan Update, error) {
 	if c.Active() {
 		return nil, errors.New("client subscription is already active")
 	}
-	if c.Blob == "" {
-		return nil, errors.New("missing blob name")
+	if err := c.setup(); err != nil {
+		return nil, err
 	}
-	if c.Secret == "" {
-		return nil, errors.New("missing secret")
+
+	c.seed()
+	return c.start(ctx, true)
+}
+
+// SubscribeWithInitial works like Subscribe, but fetches the initial value of the blob before returning, so it is
+// ready as soon as SubscribeWithInitial returns. The channel only receives subsequent changes.
+//
+// If the initial fetch fails, SubscribeWithInitial returns its error and the Client is not subscribed.
+func (c *Client) SubscribeWithInitial() ([]byte, <-chan Update, error) {
+	return c.SubscribeWithInitialContext(c.baseContext())
+}
+
+// SubscribeWithInitialContext works like SubscribeWithInitial, but ties the subscription to the lifetime of ctx, as
+// in SubscribeContext.
+func (c *Client) SubscribeWithInitialContext(ctx context.Context) ([]byte, <-chan Update, error) {
+	if c.Active() {
+		return nil, nil, errors.New("client subscription is already active")
 	}
-	if c.Host == "" {
-		c.Host = DEFAULT_HOST
+	if err := c.setup(); err != nil {
+		return nil, nil, err
 	}
-	if c.Interval == 0 {
-		c.Interval = time.Duration(DEFAULT_INTERVAL)
+
+	c.seed()
+	last, ver := c.cached()
+	same, data, ver, err := c.fetch(ctx, ver)
+	if err == nil && !same {
+		err = c.validate(data)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if same {
+		data = last
+	} else {
+		c.store(data, ver)
+	}
+	ch, err := c.start(ctx, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, ch, nil
+}
+
+// start begins a subscription, polling on a new goroutine, and returns its Update channel. If initialPoll is False,
+// the first poll waits for the interval to pass.
+func (c *Client) start(ctx context.Context, initialPoll bool) (<-chan Update, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active() {
+		// another subscription started while this one was being set up
+		return nil, errors.New("client subscription is already active")
+	}
+
+	if c.cancel != nil {
+		// release the previous subscription, which ended on its own
+		c.cancel()
 	}
+	// subCtx ends only when the subscription is canceled, while ctx also ends when polling stops
+	subCtx, cancel := context.WithCancel(ctx)
+	ctx, stop := context.WithCancel(subCtx)
+	p := &poller{
+		c:          c,
+		ctx:        ctx,
+		ch:         make(chan Update, c.ChannelBuffer),
+		sent:       !initialPoll,
+		refresh:    make(chan struct{}, 1),
+		calls:      make(chan refreshCall),
+		reschedule: make(chan struct{}, 1),
+		stagger:    c.stagger,
+		rand:       rand.New(c.randSource()),
+	}
+	done := make(chan struct{})
+	c.cancel = cancel
+	c.canceled = subCtx.Done()
+	c.done = done
+	c.updates = p.ch
+	c.refresh = p.refresh
+	c.calls = p.calls
+	c.reschedule = p.reschedule
+	c.resume = nil
+
+	atomic.AddInt64(&activeSubscriptions, 1)
+	go func() {
+		defer atomic.AddInt64(&activeSubscriptions, -1)
+		defer close(p.ch)
+		defer close(done)
+		defer stop()
+		p.run(initialPoll)
+	}()
+	return p.ch, nil
+}
+
+// ActiveSubscriptions returns the number of subscriptions, across all Clients, whose polling goroutines are still
+// running. Subscriptions which are abandoned without being canceled keep running forever, so a test can check that
+// this returns 0 after cleanup to catch leaks. A canceled subscription's goroutine stops shortly after Cancel returns.
+func ActiveSubscriptions() int {
+	return int(atomic.LoadInt64(&activeSubscriptions))
+}
+
+// Get fetches the current value of the blob once, without subscribing to updates.
+func (c *Client) Get() ([]byte, error) {
+	return c.GetContext(c.baseContext())
+}
 
-	var lastEtag *string = nil
-	ch := make(chan Update)
-	c.ticker = time.NewTicker(c.Interval)
+// GetContext works like Get, but aborts the request if ctx is canceled or its deadline passes, returning ctx.Err().
+// This suits HTTP handlers, which can pass the incoming request's context.
+func (c *Client) GetContext(ctx context.Context) ([]byte, error) {
+	if err := c.setup(); err != nil {
+		return nil, err
+	}
+	_, data, _, err := c.fetch(ctx, version{})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// WaitForValue subscribes, waits for the first value of the blob, then cancels the subscription. It returns the error
+// from the first failed fetch, or an error wrapping context.DeadlineExceeded if no value arrives within timeout.
+// The value stays cached, so a later Subscribe starts with a cheap conditional request.
+//
+// This is handy for bootstrapping in main or tests, when config is needed before continuing.
+func (c *Client) WaitForValue(timeout time.Duration) ([]byte, error) {
+	if c.OnUpdate != nil {
+		return nil, errors.New("WaitForValue can't be used with OnUpdate")
+	}
+	ctx, cancel := context.WithTimeout(c.baseContext(), timeout)
+	defer cancel()
+	updates, err := c.SubscribeContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Cancel()
+	for update := range updates {
+		if update.Error != nil {
+			return nil, update.Error
+		}
+		return update.Value, nil
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("no value within %s: %w", timeout, ctx.Err())
+	}
+	return nil, ctx.Err()
+}
 
+// Watch subscribes and keeps the latest value of the blob available through get, which is safe to call from any
+// goroutine, such as in request handlers. get returns nil until the first value arrives. Call cancel to stop watching.
+// Errors are not returned by get; set OnError to observe them.
+func (c *Client) Watch() (get func() []byte, cancel func(), err error) {
+	if c.OnUpdate != nil {
+		return nil, nil, errors.New("Watch can't be used with OnUpdate")
+	}
+	updates, err := c.Subscribe()
+	if err != nil {
+		return nil, nil, err
+	}
+	var latest atomic.Value
 	go func() {
-		for {
-			same, data, etag, err := c.fetch(lastEtag)
-			if err != nil {
-				// something went wrong
-				ch <- Update{Error: err}
-			} else if same {
-				// value has not changed; do nothing
-			} else {
-				// value has changed
-				ch <- Update{Value: data}
-				c.last = data
-				lastEtag = etag
+		for update := range updates {
+			if update.Error == nil {
+				latest.Store(update.Value)
 			}
-			<-c.ticker.C
 		}
 	}()
+	get = func() []byte {
+		value, _ := latest.Load().([]byte)
+		return value
+	}
+	return get, c.Cancel, nil
+}
 
-	return ch, nil
+// AddListener returns a new channel which receives every Update from the Client's subscription, and a function which
+// removes the listener and closes its channel. Any number of listeners may share one subscription, such as
+// independent components reacting to the same blob. A listener added after the first value receives the latest value
+// immediately.
+//
+// The first AddListener subscribes the Client, which then polls until Cancel is called, even if every listener is
+// removed. It returns an error if the Client was already subscribed with Subscribe. Each listener must keep reading
+// its channel, as polling waits for every listener to receive each Update.
+func (c *Client) AddListener() (<-chan Update, func(), error) {
+	c.listenMu.Lock()
+	defer c.listenMu.Unlock()
+
+	c.mu.Lock()
+	b := c.listeners
+	c.mu.Unlock()
+	if b == nil {
+		if c.OnUpdate != nil {
+			return nil, nil, errors.New("listeners can't be used with OnUpdate")
+		}
+		updates, err := c.Subscribe()
+		if err != nil {
+			return nil, nil, err
+		}
+		b = newBroadcast(c.ChannelBuffer, c.cancellation())
+		c.mu.Lock()
+		c.listeners = b
+		c.mu.Unlock()
+		go func() {
+			for u := range updates {
+				b.send(u)
+			}
+			b.close()
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if c.listeners == b {
+				c.listeners = nil
+			}
+		}()
+	}
+
+	l := b.add()
+	return l.ch, func() { b.remove(l) }, nil
 }
 
-// Cancel cancels a subscription. This Client will stop polling, and no further updates will be sent on its channel.
+// Cancel cancels a subscription. This Client will stop polling, no further updates will be sent on its channel, and
+// the channel will be closed. An Update which was already being sent may still be delivered before the channel closes.
+//
+// Cancel is idempotent: it is safe to call any number of times, from any goroutine, including after the subscription
+// has already ended. It doesn't wait for the polling goroutine to stop, so it's also safe to call from a Metrics hook.
 //
-// Reusing a canceled Client is not supported.
+// A canceled Client may be subscribed again. The new subscription keeps the cached value and ETag, and starts by
+// sending the current value as usual.
 func (c *Client) Cancel() {
-	if c.Active() {
-		c.ticker.Stop()
-		c.ticker = nil
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancel != nil {
+		// the polling goroutine closes the channel on its way out,
+		// so a send in progress is abandoned rather than racing with close
+		c.cancel()
+		c.cancel = nil
+	}
+}
+
+// Drain cancels the subscription, waits for polling to stop, and returns the Updates which were queued in its channel
+// but not yet received, oldest first. No Updates are produced after Drain returns. This lets a graceful shutdown
+// apply the latest known value before exiting. Drain returns nil if the Client was never subscribed, or if OnUpdate
+// is set.
+func (c *Client) Drain() []Update {
+	c.mu.Lock()
+	updates := c.updates
+	c.mu.Unlock()
+	c.Cancel()
+	if updates == nil {
+		return nil
+	}
+	var drained []Update
+	// the polling goroutine closes the channel once it stops
+	for u := range updates {
+		drained = append(drained, u)
+	}
+	return drained
+}
+
+// Refresh asks the Client to fetch the blob immediately rather than waiting for the next poll, sending an Update if
+// the value changed. The regular polling schedule is unaffected.
+//
+// Refresh does not wait for the fetch. It is safe to call concurrently; requests made while a refresh is already
+// pending are coalesced into it. Refreshing an inactive Client does nothing.
+func (c *Client) Refresh() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.active() {
+		return
+	}
+	select {
+	case c.refresh <- struct{}{}:
+	default:
+		// a refresh is already pending
+	}
+}
+
+// refreshCall is a request from RefreshContext for the polling goroutine to fetch the blob.
+type refreshCall struct {
+	ctx    context.Context
+	result chan refreshResult
+}
+
+// refreshResult is the polling goroutine's reply to a refreshCall.
+type refreshResult struct {
+	value []byte
+	err   error
+}
+
+// RefreshContext fetches the blob immediately and returns its current value, or the error if the fetch failed. ctx
+// bounds the fetch. While subscribed, the subscription's polling goroutine makes the fetch, so an Update is also sent
+// if the value changed, and the regular polling schedule is unaffected. If ctx ends before the fetch completes, only
+// the caller sees the error: no Update is sent and the subscription doesn't count it as a failure. When not
+// subscribed, the fetch just updates the cached value.
+//
+// This suits an HTTP handler which forces a refresh and reports the result.
+func (c *Client) RefreshContext(ctx context.Context) ([]byte, error) {
+	c.mu.Lock()
+	active := c.active()
+	calls, done := c.calls, c.done
+	c.mu.Unlock()
+	if !active {
+		return c.refreshInactive(ctx)
+	}
+
+	call := refreshCall{ctx: ctx, result: make(chan refreshResult, 1)}
+	select {
+	case calls <- call:
+	case <-done:
+		return nil, errors.New("subscription ended before refreshing")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	select {
+	case result := <-call.result:
+		return result.value, result.err
+	case <-ctx.Done():
+		// such as while the polling goroutine waits for a consumer to receive the Update
+		return nil, ctx.Err()
+	}
+}
+
+// refreshInactive fetches the blob for RefreshContext when the Client isn't subscribed.
+func (c *Client) refreshInactive(ctx context.Context) ([]byte, error) {
+	if err := c.setup(); err != nil {
+		return nil, err
+	}
+	last, ver := c.cached()
+	same, data, ver, err := c.fetch(ctx, ver)
+	if err == nil && !same {
+		err = c.validate(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if same {
+		return last, nil
+	}
+	c.store(data, ver)
+	return data, nil
+}
+
+// SetInterval changes the update polling interval. For an active subscription, the next poll is rescheduled to happen
+// one new interval from now. For an inactive Client, the interval is used by the next subscription.
+//
+// Intervals below MIN_INTERVAL are rejected, as in Subscribe.
+func (c *Client) SetInterval(d time.Duration) error {
+	if d < MIN_INTERVAL {
+		return fmt.Errorf("interval must be at least %s", MIN_INTERVAL)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Interval = d
+	if c.active() {
+		select {
+		case c.reschedule <- struct{}{}:
+		default:
+			// a reschedule is already pending
+		}
 	}
+	return nil
+}
+
+// Pause stops polling until Resume is called, without ending the subscription. The Client keeps its cached value and
+// ETag, so once it resumes it only sends an Update if the blob changed while it was paused.
+//
+// Pausing an inactive or already-paused Client does nothing.
+func (c *Client) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active() && c.resume == nil {
+		c.resume = make(chan struct{})
+	}
+}
+
+// Resume restarts polling for a paused subscription. If a poll came due while paused, it happens immediately.
+//
+// Resuming a Client which isn't paused does nothing.
+func (c *Client) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.resume != nil {
+		close(c.resume)
+		c.resume = nil
+	}
+}
+
+// Paused returns True if this Client's subscription is paused. A paused subscription is still Active.
+func (c *Client) Paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.resume != nil && c.active()
+}
+
+// Value returns the last-retrieved value of the blob, and whether any value has been retrieved yet. It is safe to call
+// from any goroutine while subscribed, such as to serve an HTTP request with the current value.
+//
+// A value from InitialValue counts as retrieved.
+func (c *Client) Value() ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last, c.hasValue
+}
+
+// HasValue returns True if the Client has retrieved a value of the blob, which distinguishes an empty value from no
+// value at all, such as for readiness checks. Like Value, a value from InitialValue counts as retrieved.
+func (c *Client) HasValue() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hasValue
+}
+
+// ETag returns the ETag of the last-retrieved value of the blob, or "" if there is none. Save this along with the
+// value to seed InitialETag and InitialValue when the process restarts.
+func (c *Client) ETag() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ver.etag
+}
+
+// LastSuccess returns when the Client last fetched the blob successfully, whether or not its value had changed, and
+// whether it ever has.
+func (c *Client) LastSuccess() (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastSuccess, !c.lastSuccess.IsZero()
+}
+
+// Healthy returns True if the Client has fetched the blob successfully within maxAge, such as for a readiness probe.
+func (c *Client) Healthy(maxAge time.Duration) bool {
+	t, ok := c.LastSuccess()
+	return ok && c.now().Sub(t) <= maxAge
+}
+
+// Stats returns counters describing the fetches the Client has made since it was created, by subscriptions and Get.
+func (c *Client) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
 }
 
 // Active returns True if this Client is actively subscribed to a blob and False otherwise.
+//
+// A subscription stops being active when it is canceled, when its context is canceled, or after a fatal error.
 func (c *Client) Active() bool {
-	return c.ticker != nil
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.active()
+}
+
+// active implements Active. The caller must hold c.mu.
+func (c *Client) active() bool {
+	if c.cancel == nil {
+		// never subscribed, or canceled
+		return false
+	}
+	select {
+	case <-c.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// cancellation returns a channel which is closed when the current subscription is canceled, by Cancel or its context,
+// or nil if there has been none. It stays open when the subscription ends on its own, such as after a fatal error, so
+// forwarders can deliver the final Update.
+func (c *Client) cancellation() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.canceled
+}
+
+// seed fills the cache from CacheFile, or else InitialETag and InitialValue, if nothing has been fetched yet.
+func (c *Client) seed() {
+	cached, cachedVer, ok := c.loadCache()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.hasValue || c.ver != (version{}) {
+		return
+	}
+	if ok {
+		c.last = cached
+		c.hasValue = true
+		c.ver = cachedVer
+		return
+	}
+	c.last = c.InitialValue
+	c.hasValue = c.InitialValue != nil
+	c.ver = version{etag: c.InitialETag}
+}
+
+// cached returns the last-retrieved value for the blob and its cache validators.
+func (c *Client) cached() ([]byte, version) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last, c.ver
+}
+
+// store caches a newly retrieved value for the blob.
+func (c *Client) store(data []byte, ver version) {
+	c.mu.Lock()
+	c.last = data
+	c.hasValue = true
+	c.ver = ver
+	c.stats.LastChange = c.now()
+	c.mu.Unlock()
+	c.saveCache(data, ver)
+}
+
+// validate returns a ValidationError if value is empty and RejectEmpty is set, or if it fails Validate.
+func (c *Client) validate(value []byte) error {
+	if c.RejectEmpty && len(value) == 0 {
+		return &ValidationError{Err: ErrEmptyValue, Value: value}
+	}
+	if c.Validate == nil {
+		return nil
+	}
+	if err := c.Validate(value); err != nil {
+		return &ValidationError{Err: err, Value: value}
+	}
+	return nil
+}
+
+// equal returns True if a new value of the blob is the same as the old one, according to EqualFunc.
+func (c *Client) equal(old, new []byte) bool {
+	if c.EqualFunc != nil {
+		return c.EqualFunc(old, new)
+	}
+	return bytes.Equal(old, new)
+}
+
+// revalidate records new cache validators for the value already in the cache.
+func (c *Client) revalidate(ver version) {
+	c.mu.Lock()
+	c.ver = ver
+	data := c.last
+	c.mu.Unlock()
+	c.saveCache(data, ver)
+}
+
+// setup validates the Client's configuration and fills in defaults for optional fields.
+func (c *Client) setup() error {
+	if err := validateBlob(c.Blob); err != nil {
+		return err
+	}
+	return c.setupClient()
 }
 
-// fetch retrieves the latest value for the blob, obeying caching logic if we have a copy of this blob from the past.
-func (c *Client) fetch(lastEtag *string) (same bool, data []byte, etag *string, err error) {
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/%s", c.Host, c.Blob), nil)
+// setupClient works like setup, but doesn't require a blob, for requests which aren't about a single blob.
+func (c *Client) setupClient() error {
+	if c.Secret == "" {
+		return errors.New("missing secret")
+	}
+	if c.Host == "" {
+		c.Host = DEFAULT_HOST
+	}
+	host, err := normalizeHost(c.Host)
 	if err != nil {
-		return false, nil, nil, err
+		return err
+	}
+	if host != c.Host {
+		// only write when needed, since setup also runs while a subscription is polling
+		c.Host = host
+	}
+	hosts := make([]string, len(c.Hosts))
+	changed := false
+	for i, host := range c.Hosts {
+		if host == "" {
+			return errors.New("hosts must not be empty")
+		}
+		if hosts[i], err = normalizeHost(host); err != nil {
+			return err
+		}
+		changed = changed || hosts[i] != host
+	}
+	if changed {
+		c.Hosts = hosts
+	}
+	// SetInterval may change the interval while a subscription is running
+	c.mu.Lock()
+	if c.Interval == 0 {
+		c.Interval = time.Duration(DEFAULT_INTERVAL)
+	}
+	interval := c.Interval
+	c.mu.Unlock()
+	if interval < 0 {
+		return errors.New("interval must not be negative")
+	}
+	if interval < MIN_INTERVAL {
+		return fmt.Errorf("interval must be at least %s", MIN_INTERVAL)
+	}
+	if c.Timeout == 0 {
+		c.Timeout = DEFAULT_TIMEOUT
+	}
+	if c.Timeout < 0 {
+		return errors.New("timeout must not be negative")
+	}
+	if c.Jitter < 0 {
+		return errors.New("jitter must not be negative")
+	}
+	if c.Jitter > 0 && c.Jitter >= interval {
+		return errors.New("jitter must be less than the interval")
+	}
+	if c.DebounceWindow < 0 {
+		return errors.New("debounce window must not be negative")
+	}
+	if c.DebounceWindow > 0 && c.DebounceWindow < MIN_INTERVAL {
+		return fmt.Errorf("debounce window must be at least %s", MIN_INTERVAL)
+	}
+	if c.ChannelBuffer == 0 {
+		c.ChannelBuffer = DEFAULT_CHANNEL_BUFFER
+	}
+	if c.ChannelBuffer < 0 {
+		return errors.New("channel buffer must not be negative")
+	}
+	if c.MaxBodySize == 0 {
+		c.MaxBodySize = DEFAULT_MAX_BODY_SIZE
+	}
+	if c.MaxBodySize < 0 {
+		return errors.New("max body size must not be negative")
 	}
-	req.Header.Add("User-Agent", userAgent)
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.Secret))
-	if lastEtag != nil {
-		req.Header.Add("If-None-Match", *lastEtag)
+	if c.MaxBackoff == 0 {
+		c.MaxBackoff = DEFAULT_MAX_BACKOFF
 	}
-	resp, err := client.Do(req)
+	if c.MaxBackoff < 0 {
+		return errors.New("max backoff must not be negative")
+	}
+	if c.BackoffMultiplier == 0 {
+		c.BackoffMultiplier = DEFAULT_BACKOFF_MULTIPLIER
+	}
+	if c.BackoffMultiplier < 1 {
+		return errors.New("backoff multiplier must be at least 1")
+	}
+	if c.FatalStatusCodes == nil {
+		c.FatalStatusCodes = defaultFatalStatusCodes
+	}
+	if c.AcceptStatus == nil {
+		c.AcceptStatus = defaultAcceptStatus
+	}
+	if c.StartDelay < 0 {
+		return errors.New("start delay must not be negative")
+	}
+	if c.InitialRetries < 0 {
+		return errors.New("initial retries must not be negative")
+	}
+	if c.InitialRetryDelay == 0 {
+		c.InitialRetryDelay = DEFAULT_INITIAL_RETRY_DELAY
+	}
+	if c.InitialRetryDelay < 0 {
+		return errors.New("initial retry delay must not be negative")
+	}
+	if c.InTickRetries < 0 {
+		return errors.New("in-tick retries must not be negative")
+	}
+	if c.InTickRetryDelay == 0 {
+		c.InTickRetryDelay = DEFAULT_IN_TICK_RETRY_DELAY
+	}
+	if c.InTickRetryDelay < 0 {
+		return errors.New("in-tick retry delay must not be negative")
+	}
+	if c.MaxConsecutiveErrors < 0 {
+		return errors.New("max consecutive errors must not be negative")
+	}
+	if c.BreakerCooldown == 0 {
+		c.BreakerCooldown = DEFAULT_BREAKER_COOLDOWN
+	}
+	if c.BreakerCooldown < 0 {
+		return errors.New("breaker cooldown must not be negative")
+	}
+	if c.DialTimeout == 0 {
+		c.DialTimeout = DEFAULT_DIAL_TIMEOUT
+	}
+	if c.DialTimeout < 0 {
+		return errors.New("dial timeout must not be negative")
+	}
+	if c.HTTPClient == nil && c.ownHTTPClient == nil && c.transportConfigured() {
+		c.ownHTTPClient = &http.Client{Transport: c.newTransport()}
+	}
+	return nil
+}
+
+// validateBlob returns an error if name can't be a blob name.
+func validateBlob(name string) error {
+	if name == "" {
+		return errors.New("missing blob name")
+	}
+	if name == "." || name == ".." {
+		return fmt.Errorf("invalid blob name %q", name)
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("invalid blob name %q: contains control characters", name)
+		}
+	}
+	return nil
+}
+
+// normalizeHost returns host as a base URL for requests, defaulting its scheme to https and removing trailing
+// slashes, or an error if it isn't a valid http or https URL.
+func normalizeHost(host string) (string, error) {
+	normalized := host
+	if !strings.Contains(normalized, "://") {
+		normalized = "https://" + normalized
+	}
+	normalized = strings.TrimRight(normalized, "/")
+	u, err := url.Parse(normalized)
+	if err != nil {
+		return "", fmt.Errorf("invalid host %q: %w", host, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid host %q: scheme must be http or https", host)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("invalid host %q: missing hostname", host)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("invalid host %q: must not have a query or fragment", host)
+	}
+	return normalized, nil
+}
+
+// retryAfter returns how long the server asked the Client to wait before polling again after err, or 0.
+func retryAfter(err error) time.Duration {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.RetryAfter
+	}
+	return 0
+}
+
+// statusCode returns the HTTP status code of the response that caused err, or 0 if there was no response.
+func statusCode(err error) int {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode
+	}
+	return 0
+}
+
+// fatal returns True if err means that polling for this blob can never succeed.
+func (c *Client) fatal(err error) bool {
+	var se *StatusError
+	if !errors.As(err, &se) {
+		return false
+	}
+	for _, code := range c.FatalStatusCodes {
+		if se.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns how long to wait before the next poll, backing off exponentially after consecutive failed fetches.
+func (c *Client) delay(failures int) time.Duration {
+	c.mu.Lock()
+	interval := c.Interval
+	c.mu.Unlock()
+
+	d := interval
+	for i := 0; i < failures && d < c.MaxBackoff; i++ {
+		d = time.Duration(float64(d) * c.BackoffMultiplier)
+	}
+	if d > c.MaxBackoff && c.MaxBackoff > interval {
+		d = c.MaxBackoff
+	}
+	return d
+}
+
+// retryDelay returns the delay before a quick retry of the initial fetch after the given number of failures.
+func (c *Client) retryDelay(failures int) time.Duration {
+	c.mu.Lock()
+	interval := c.Interval
+	c.mu.Unlock()
+
+	d := c.InitialRetryDelay
+	for i := 1; i < failures && d < interval; i++ {
+		d *= 2
+	}
+	if d > interval {
+		d = interval
+	}
+	return d
+}
+
+// randSource returns the source of randomness for a new subscription.
+func (c *Client) randSource() rand.Source {
+	if c.RandSource != nil {
+		return c.RandSource
+	}
+	return rand.NewSource(time.Now().UnixNano())
+}
+
+// baseContext returns the context for requests made by methods which don't take one.
+func (c *Client) baseContext() context.Context {
+	if c.baseCtx != nil {
+		return c.baseCtx
+	}
+	return context.Background()
+}
+
+// httpClient returns the HTTP client this Client should use to make requests.
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	if c.ownHTTPClient != nil {
+		return c.ownHTTPClient
+	}
+	return defaultHTTPClient
+}
+
+// fetchFrom retrieves the latest value for the blob from host, obeying caching logic if we have a copy of this blob
+// from the past.
+func (c *Client) fetchFrom(ctx context.Context, host string, last version) (same bool, data []byte, ver version,
+	err error) {
+	fetchCtx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+	req, err := c.fetchRequest(fetchCtx, host, last)
+	if err != nil {
+		return false, nil, version{}, err
+	}
+	var finish func(FetchInfo)
+	if c.Tracer != nil {
+		req, finish = c.Tracer.StartFetch(req)
+	}
+
+	start := c.now()
+	same, data, ver, err = c.doFetch(req)
+	if err != nil && ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("fetch timed out after %s: %w", c.Timeout, err)
+	}
+	info := c.fetchInfo(c.now().Sub(start), same, data, err)
+	c.mu.Lock()
+	if err == nil {
+		c.lastSuccess = c.now()
+	}
+	c.stats.record(info)
+	c.mu.Unlock()
+	if finish != nil {
+		finish(info)
+	}
+	if c.Metrics != nil {
+		c.Metrics.OnFetch(info)
+	}
+	if c.Logger != nil {
+		c.Logger.Log(LogEntry{Time: c.now(), Blob: c.Blob, Host: host, Status: info.StatusCode,
+			Duration: info.Duration, CacheHit: info.NotModified, Error: err})
+	}
+	return same, data, ver, err
+}
+
+// fetchInfo describes the result of a fetch for reporting to Metrics and Tracer.
+func (c *Client) fetchInfo(duration time.Duration, same bool, data []byte, err error) FetchInfo {
+	info := FetchInfo{Blob: c.Blob, Duration: duration, Bytes: len(data), NotModified: same, Error: err}
+	var se *StatusError
+	switch {
+	case errors.As(err, &se):
+		info.StatusCode = se.StatusCode
+		info.Bytes = len(se.Body)
+	case err != nil:
+		// no response was received
+	case same:
+		info.StatusCode = http.StatusNotModified
+	default:
+		info.StatusCode = http.StatusOK
+	}
+	return info
+}
+
+// newRequest builds an authenticated request to the blob's endpoint on host.
+func (c *Client) newRequest(ctx context.Context, host, method string, body io.Reader) (*http.Request, error) {
+	return c.newRequestURL(ctx, method, fmt.Sprintf("%s/%s", host, url.PathEscape(c.Blob)), body)
+}
+
+// newRequestURL works like newRequest, but for an arbitrary URL.
+func (c *Client) newRequestURL(ctx context.Context, method, target string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range c.Headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	// the headers the Client manages take precedence over Headers
+	req.Header.Set("User-Agent", c.userAgent())
+	c.authorize(req)
+	return req, nil
+}
+
+// userAgent returns the User-Agent the Client sends.
+func (c *Client) userAgent() string {
+	if c.UserAgentSuffix == "" {
+		return userAgent
+	}
+	return userAgent + " " + c.UserAgentSuffix
+}
+
+// authorize adds the Client's credentials to req.
+func (c *Client) authorize(req *http.Request) {
+	if c.Authorize != nil {
+		c.Authorize(req)
+		return
+	}
+	header, scheme := c.AuthHeader, c.AuthScheme
+	if header == "" {
+		header = "Authorization"
+		if scheme == "" {
+			scheme = "Bearer"
+		}
+	}
+	if scheme == "" {
+		req.Header.Set(header, c.Secret)
+	} else {
+		req.Header.Set(header, fmt.Sprintf("%s %s", scheme, c.Secret))
+	}
+}
+
+// fetchRequest builds the request for fetch, which is conditional on the last value's cache validators. ctx bounds
+// the request and the reading of its response body.
+func (c *Client) fetchRequest(ctx context.Context, host string, last version) (*http.Request, error) {
+	req, err := c.newRequest(ctx, host, http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+	// setting this ourselves disables the transport's transparent decompression, so readBody handles it instead
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Del("If-None-Match")
+	req.Header.Del("If-Modified-Since")
+	if c.DisableConditional {
+		return req, nil
+	}
+	if last.etag != "" {
+		req.Header.Set("If-None-Match", last.etag)
+	} else if last.lastModified != "" {
+		req.Header.Set("If-Modified-Since", last.lastModified)
+	}
+	return req, nil
+}
+
+// doFetch performs the request for fetch and interprets the response.
+func (c *Client) doFetch(req *http.Request) (same bool, data []byte, ver version, err error) {
+	resp, err := c.httpClient().Do(req)
 	if err != nil {
-		return false, nil, nil, err
+		return false, nil, version{}, &NetworkError{Err: err}
 	}
-	data, err = ioutil.ReadAll(resp.Body)
+	defer closeBody(resp)
+	if c.OnResponse != nil {
+		inspected := *resp
+		inspected.Header = resp.Header.Clone()
+		inspected.Body = http.NoBody
+		c.OnResponse(&inspected)
+	}
+	data, err = c.readBody(resp)
 	if err != nil {
-		return false, nil, nil, err
+		return false, nil, version{}, err
+	}
+	if resp.StatusCode == http.StatusNotModified && !c.DisableConditional && !c.unexpected304(req) {
+		return true, nil, version{}, err
 	}
-	if resp.StatusCode == http.StatusNotModified {
-		return true, nil, nil, err
+	if !c.accepted(resp.StatusCode) {
+		return false, nil, version{}, newStatusError(resp, data, c.now())
+	}
+	if err := c.checkContentType(resp); err != nil {
+		return false, nil, version{}, err
+	}
+	ver = version{etag: resp.Header.Get("ETag"), lastModified: resp.Header.Get("Last-Modified")}
+	return false, data, ver, err
+}
+
+// unexpected304 returns True if StrictCaching is set and req wasn't conditional, so a 304 response to it means a
+// cache or proxy is misbehaving.
+func (c *Client) unexpected304(req *http.Request) bool {
+	return c.StrictCaching && req.Header.Get("If-None-Match") == "" && req.Header.Get("If-Modified-Since") == ""
+}
+
+// accepted returns True if a response with the given status code carries the value of the blob.
+func (c *Client) accepted(statusCode int) bool {
+	for _, code := range c.AcceptStatus {
+		if statusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// checkContentType returns a ContentTypeError if ExpectContentType is set and resp has a different media type.
+func (c *Client) checkContentType(resp *http.Response) error {
+	if c.ExpectContentType == "" {
+		return nil
+	}
+	got := resp.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(got)
+	if err != nil || !strings.EqualFold(mediaType, c.ExpectContentType) {
+		return &ContentTypeError{Expected: c.ExpectContentType, Got: got}
+	}
+	return nil
+}
+
+// closeBody drains what's left of resp's body, up to a limit, then closes it, so the connection can be reused.
+func closeBody(resp *http.Response) {
+	if resp.Body == nil {
+		return
+	}
+	// past this much, dropping the connection is cheaper than reading the rest
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+}
+
+// readBody reads the entire body of resp, decompressing it if the server gzipped it. It returns a BodyTooLargeError
+// rather than reading more than MaxBodySize bytes.
+func (c *Client) readBody(resp *http.Response) ([]byte, error) {
+	var body io.Reader = resp.Body
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(resp.Body)
+		if err == io.EOF {
+			// empty bodies, such as on a 304, aren't gzipped even if the header says so
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress response body: %w", err)
+		}
+		defer gz.Close()
+		body = gz
+	}
+	// read one byte past the limit to tell a body of exactly MaxBodySize from a larger one
+	data, err := ioutil.ReadAll(io.LimitReader(body, c.MaxBodySize+1))
+	if err != nil {
+		return nil, &NetworkError{Err: err}
 	}
-	if resp.StatusCode != http.StatusOK {
-		err = fmt.Errorf("expected status code %d but got %d: `%s`", http.StatusOK, resp.StatusCode, data)
-		return false, nil, nil, err
+	if int64(len(data)) > c.MaxBodySize {
+		return nil, &BodyTooLargeError{Limit: c.MaxBodySize}
 	}
-	t := resp.Header.Get("ETag")
-	return false, data, &t, err
+	return data, nil
 }
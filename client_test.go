@@ -1,9 +1,21 @@
 package client_test
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -15,6 +27,9 @@ func TestLive(t *testing.T) {
 	secret := os.Getenv("SECRET")
 	blob := os.Getenv("BLOB")
 	host := os.Getenv("HOST")
+	if secret == "" && blob == "" {
+		t.Skip("Set SECRET and BLOB env vars to run the live test")
+	}
 	if secret == "" {
 		log.Fatal("Must provide SECRET env var")
 	}
@@ -39,3 +54,2207 @@ func TestLive(t *testing.T) {
 		fmt.Printf("Update: value: %s, error: %+v\n", data.Value, data.Error)
 	}
 }
+
+// newServer starts a fake Viteset API which serves requests with handler, and returns a Client pointed at it.
+func newServer(t *testing.T, handler http.HandlerFunc) *viteset.Client {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	c := &viteset.Client{Blob: "some-blob", Secret: "some-secret", Host: srv.URL}
+	t.Cleanup(c.Cancel)
+	return c
+}
+
+// receive waits for the next Update on ch.
+func receive(t *testing.T, ch <-chan viteset.Update) viteset.Update {
+	t.Helper()
+	select {
+	case update, ok := <-ch:
+		if !ok {
+			t.Fatal("channel closed before an update arrived")
+		}
+		return update
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an update")
+	}
+	return viteset.Update{}
+}
+
+// waitInactive waits for c's subscription to end, failing the test if it doesn't end in time.
+func waitInactive(t *testing.T, c viteset.Subscriber) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Active() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the subscription to end")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// subscribe subscribes c, failing the test on error.
+func subscribe(t *testing.T, c *viteset.Client) <-chan viteset.Update {
+	t.Helper()
+	ch, err := c.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ch
+}
+
+func TestSubscribeOK(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/some-blob" {
+			t.Errorf("expected path /some-blob but got %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer some-secret" {
+			t.Errorf("expected bearer auth but got %q", got)
+		}
+		w.Write([]byte("some value"))
+	})
+	update := receive(t, subscribe(t, c))
+	if update.Error != nil {
+		t.Fatal(update.Error)
+	}
+	if string(update.Value) != "some value" {
+		t.Errorf("expected `some value` but got `%s`", update.Value)
+	}
+}
+
+func TestSubscribeNotModified(t *testing.T) {
+	fetches := make(chan string, 10)
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		fetches <- r.Header.Get("If-None-Match")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("some value"))
+	})
+	ch := subscribe(t, c)
+	if update := receive(t, ch); update.ETag != `"v1"` {
+		t.Errorf("expected the Update to carry the ETag \"v1\" but got %q", update.ETag)
+	}
+	c.Refresh()
+
+	if etag := <-fetches; etag != "" {
+		t.Errorf("expected no If-None-Match on the first fetch but got %q", etag)
+	}
+	if etag := <-fetches; etag != `"v1"` {
+		t.Errorf("expected If-None-Match to be the last ETag but got %q", etag)
+	}
+	select {
+	case update := <-ch:
+		t.Errorf("expected no update for an unchanged blob but got %+v", update)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSubscribeUnauthorized(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	ch := subscribe(t, c)
+	update := receive(t, ch)
+	var ae *viteset.AuthError
+	if !errors.As(update.Error, &ae) {
+		t.Fatalf("expected an AuthError but got %v", update.Error)
+	}
+	if update.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status code 401 but got %d", update.StatusCode)
+	}
+	if _, ok := <-ch; ok {
+		t.Error("expected the channel to close after an auth failure")
+	}
+}
+
+func TestSubscribeServerError(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error": "oops", "code": "internal"}`))
+	})
+	ch := subscribe(t, c)
+	update := receive(t, ch)
+	var se *viteset.ServerError
+	if !errors.As(update.Error, &se) {
+		t.Fatalf("expected a ServerError but got %v", update.Error)
+	}
+	if se.Message != "oops" || se.Code != "internal" {
+		t.Errorf("expected the error envelope to be decoded but got %+v", se.StatusError)
+	}
+	if !c.Active() {
+		t.Error("expected the subscription to continue after a server error")
+	}
+}
+
+func TestSubscribeNetworkError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	c := &viteset.Client{Blob: "some-blob", Secret: "some-secret", Host: srv.URL}
+	defer c.Cancel()
+	update := receive(t, subscribe(t, c))
+	var ne *viteset.NetworkError
+	if !errors.As(update.Error, &ne) {
+		t.Fatalf("expected a NetworkError but got %v", update.Error)
+	}
+	if update.StatusCode != 0 {
+		t.Errorf("expected status code 0 but got %d", update.StatusCode)
+	}
+}
+
+func TestSet(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("expected a PUT but got %s", r.Method)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) == "forbidden" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	if err := c.Set([]byte("new value")); err != nil {
+		t.Fatal(err)
+	}
+	var ae *viteset.AuthError
+	if err := c.Set([]byte("forbidden")); !errors.As(err, &ae) {
+		t.Errorf("expected an AuthError but got %v", err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			t.Errorf("expected a DELETE but got %s", r.Method)
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+	var nf *viteset.NotFoundError
+	if err := c.Delete(); !errors.As(err, &nf) {
+		t.Errorf("expected a NotFoundError but got %v", err)
+	}
+	c.DeleteIgnoreNotFound = true
+	if err := c.Delete(); err != nil {
+		t.Errorf("expected a missing blob to be ignored but got %v", err)
+	}
+}
+
+func TestRegistryShared(t *testing.T) {
+	var requests int64
+	var mu sync.Mutex
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		w.Write([]byte("hello"))
+	})
+	other := &viteset.Client{Blob: c.Blob, Secret: c.Secret, Host: c.Host}
+	reg := &viteset.Registry{}
+
+	first, cancelFirst, err := reg.Subscribe(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if update := receive(t, first); string(update.Value) != "hello" {
+		t.Errorf("expected hello, got %q", update.Value)
+	}
+	second, cancelSecond, err := reg.Subscribe(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if update := receive(t, second); string(update.Value) != "hello" {
+		t.Errorf("expected the last value on join, got %q", update.Value)
+	}
+	mu.Lock()
+	if requests != 1 {
+		t.Errorf("expected 1 request, got %d", requests)
+	}
+	mu.Unlock()
+
+	cancelFirst()
+	if !c.Active() {
+		t.Error("expected the shared poller to keep running while subscribed")
+	}
+	cancelSecond()
+	if _, ok := <-second; ok {
+		t.Error("expected the channel to close after cancel")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if c.Active() {
+		t.Error("expected the shared poller to stop after the last cancel")
+	}
+}
+
+func TestSubscribeSeededNotModified(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != `"v1"` {
+			t.Errorf("expected the seeded ETag, got %q", r.Header.Get("If-None-Match"))
+		}
+		w.WriteHeader(http.StatusNotModified)
+	})
+	c.InitialETag = `"v1"`
+	c.InitialValue = []byte("seeded")
+	ch := subscribe(t, c)
+	update := receive(t, ch)
+	if update.Error != nil {
+		t.Fatal(update.Error)
+	}
+	if string(update.Value) != "seeded" {
+		t.Errorf("expected the seeded value, got %q", update.Value)
+	}
+
+	c.Refresh()
+	select {
+	case update := <-ch:
+		t.Errorf("expected no update for a later 304, got %+v", update)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSubscribeDefaultValue(t *testing.T) {
+	var mu sync.Mutex
+	up := false
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !up {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("real"))
+	})
+	c.DefaultValue = []byte("default")
+	ch := subscribe(t, c)
+	if update := receive(t, ch); update.Error == nil {
+		t.Fatal("expected an error update first")
+	}
+	update := receive(t, ch)
+	if update.Error != nil || !update.Fallback || string(update.Value) != "default" {
+		t.Errorf("expected a fallback update with the default value, got %+v", update)
+	}
+
+	mu.Lock()
+	up = true
+	mu.Unlock()
+	c.Refresh()
+	update = receive(t, ch)
+	if update.Fallback || string(update.Value) != "real" {
+		t.Errorf("expected the real value, got %+v", update)
+	}
+}
+
+func TestSubscribeInitialRetries(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("hello"))
+	})
+	c.InitialRetries = 3
+	c.InitialRetryDelay = 10 * time.Millisecond
+	update := receive(t, subscribe(t, c))
+	if update.Error != nil {
+		t.Fatalf("expected the retried errors to be held back, got %v", update.Error)
+	}
+	if string(update.Value) != "hello" {
+		t.Errorf("expected hello, got %q", update.Value)
+	}
+}
+
+func TestOnResponse(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "abc123")
+		w.Write([]byte("hello"))
+	})
+	requestIDs := make(chan string, 1)
+	c.OnResponse = func(resp *http.Response) {
+		requestIDs <- resp.Header.Get("X-Request-Id")
+	}
+	update := receive(t, subscribe(t, c))
+	if string(update.Value) != "hello" {
+		t.Errorf("expected the body to still be read, got %q", update.Value)
+	}
+	if id := <-requestIDs; id != "abc123" {
+		t.Errorf("expected request ID abc123, got %q", id)
+	}
+}
+
+func TestStats(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("hello"))
+	})
+	if _, err := c.Get(); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, subscribe(t, c))
+	stats := c.Stats()
+	if stats.Fetches != 2 || stats.Successes != 2 || stats.NotModified != 0 || stats.Errors != 0 {
+		t.Errorf("unexpected counts: %+v", stats)
+	}
+	if stats.Bytes != 10 {
+		t.Errorf("expected 10 bytes, got %d", stats.Bytes)
+	}
+	if stats.LastChange.IsZero() {
+		t.Error("expected LastChange to be set")
+	}
+}
+
+func TestUserAgentSuffix(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if ua := r.Header.Get("User-Agent"); ua != "Viteset-Client-Go/"+viteset.VERSION+" myservice/2.3.1" {
+			t.Errorf("unexpected User-Agent %q", ua)
+		}
+		w.Write([]byte("hello"))
+	})
+	c.UserAgentSuffix = "myservice/2.3.1"
+	if _, err := c.Get(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDisableConditional(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" {
+			t.Errorf("expected no If-None-Match, got %q", r.Header.Get("If-None-Match"))
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("hello"))
+	})
+	c.DisableConditional = true
+	ch := subscribe(t, c)
+	receive(t, ch)
+	c.Refresh()
+	if update := receive(t, ch); string(update.Value) != "hello" {
+		t.Errorf("expected the full body again, got %q", update.Value)
+	}
+}
+
+func TestBlobNameEscaped(t *testing.T) {
+	for _, blob := range []string{"my config/prod", "a?b=c", "100%", "hash#tag"} {
+		c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.RawQuery != "" {
+				t.Errorf("%q: expected no query, got %q", blob, r.URL.RawQuery)
+			}
+			if r.URL.Path != "/"+blob {
+				t.Errorf("%q: expected the blob name as the whole path, got %q", blob, r.URL.Path)
+			}
+			w.Write([]byte("hello"))
+		})
+		c.Blob = blob
+		if _, err := c.Get(); err != nil {
+			t.Errorf("%q: %v", blob, err)
+		}
+	}
+}
+
+func TestBlobNameInvalid(t *testing.T) {
+	for _, blob := range []string{"", ".", "..", "line\nbreak"} {
+		c := &viteset.Client{Blob: blob, Secret: "some-secret"}
+		if _, err := c.Subscribe(); err == nil {
+			c.Cancel()
+			t.Errorf("%q: expected an error", blob)
+		}
+	}
+}
+
+func TestCompareBytes(t *testing.T) {
+	var mu sync.Mutex
+	version := 0
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("If-None-Match") == fmt.Sprintf(`"v%d"`, version) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		// every save bumps the ETag, even when the bytes are the same
+		version++
+		w.Header().Set("ETag", fmt.Sprintf(`"v%d"`, version))
+		w.Write([]byte("hello"))
+	})
+	c.CompareBytes = true
+	ch := subscribe(t, c)
+	receive(t, ch)
+
+	mu.Lock()
+	version++
+	mu.Unlock()
+	c.Refresh()
+	select {
+	case update := <-ch:
+		t.Errorf("expected no update for identical bytes, got %+v", update)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if etag := c.ETag(); etag != `"v3"` {
+		t.Errorf("expected the new ETag to be stored, got %q", etag)
+	}
+}
+
+func TestWaitForValue(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	value, err := c.WaitForValue(5 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != "hello" {
+		t.Errorf("expected hello, got %q", value)
+	}
+	if c.Active() {
+		t.Error("expected the subscription to be canceled")
+	}
+}
+
+func TestWaitForValueTimeout(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	c.InitialRetries = 100
+	c.InitialRetryDelay = 10 * time.Millisecond
+	if _, err := c.WaitForValue(100 * time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+}
+
+func TestUpdateInitial(t *testing.T) {
+	var mu sync.Mutex
+	value := "first"
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write([]byte(value))
+	})
+	ch := subscribe(t, c)
+	if update := receive(t, ch); !update.Initial {
+		t.Error("expected the first value to be Initial")
+	}
+	mu.Lock()
+	value = "second"
+	mu.Unlock()
+	c.Refresh()
+	if update := receive(t, ch); update.Initial {
+		t.Error("expected later values not to be Initial")
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	t.Cleanup(srv.Close)
+	c := &viteset.Client{Blob: "some-blob", Secret: "some-secret", Host: srv.URL}
+	if _, err := c.Get(); err == nil {
+		t.Fatal("expected an error for an untrusted certificate")
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	c = &viteset.Client{Blob: "some-blob", Secret: "some-secret", Host: srv.URL, TLSConfig: &tls.Config{RootCAs: roots}}
+	value, err := c.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != "hello" {
+		t.Errorf("expected hello, got %q", value)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	c.MaxConsecutiveErrors = 2
+	ch := subscribe(t, c)
+	if update := receive(t, ch); update.Error == nil {
+		t.Fatal("expected an error")
+	}
+	c.Refresh()
+	update := receive(t, ch)
+	var coe *viteset.CircuitOpenError
+	if !errors.As(update.Error, &coe) {
+		t.Fatalf("expected a CircuitOpenError, got %v", update.Error)
+	}
+	if coe.Failures != 2 {
+		t.Errorf("expected 2 failures, got %d", coe.Failures)
+	}
+	var se *viteset.ServerError
+	if !errors.As(update.Error, &se) {
+		t.Errorf("expected the last error to be wrapped, got %v", update.Error)
+	}
+
+	c.Refresh()
+	select {
+	case update := <-ch:
+		t.Errorf("expected no update while the breaker is open, got %+v", update)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestRecovered(t *testing.T) {
+	var mu sync.Mutex
+	down := false
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("hello"))
+	})
+	ch := subscribe(t, c)
+	if update := receive(t, ch); update.Recovered {
+		t.Error("expected the first value not to be Recovered")
+	}
+
+	mu.Lock()
+	down = true
+	mu.Unlock()
+	c.Refresh()
+	if update := receive(t, ch); update.Error == nil {
+		t.Fatal("expected an error")
+	}
+
+	mu.Lock()
+	down = false
+	mu.Unlock()
+	c.Refresh()
+	update := receive(t, ch)
+	if !update.Recovered || !update.Unchanged || string(update.Value) != "hello" {
+		t.Errorf("expected an unchanged Recovered update, got %+v", update)
+	}
+}
+
+func TestHostsFailover(t *testing.T) {
+	var mu sync.Mutex
+	primaryRequests := 0
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		primaryRequests++
+		mu.Unlock()
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(primary.Close)
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	t.Cleanup(secondary.Close)
+
+	c := &viteset.Client{Blob: "some-blob", Secret: "some-secret", Hosts: []string{primary.URL, secondary.URL}}
+	for i := 0; i < 2; i++ {
+		value, err := c.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(value) != "hello" {
+			t.Errorf("expected hello, got %q", value)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if primaryRequests != 1 {
+		t.Errorf("expected the failed primary to be skipped until its backoff passes, got %d requests", primaryRequests)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	var mu sync.Mutex
+	version := 0
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		version++
+		fmt.Fprintf(w, "v%d", version)
+	})
+	c.ChannelBuffer = 5
+	ch := subscribe(t, c)
+	for queued := 1; queued <= 3; queued++ {
+		for len(ch) < queued {
+			time.Sleep(time.Millisecond)
+		}
+		if queued < 3 {
+			c.Refresh()
+		}
+	}
+
+	updates := c.Drain()
+	if len(updates) != 3 {
+		t.Fatalf("expected 3 queued updates, got %d", len(updates))
+	}
+	if string(updates[2].Value) != "v3" {
+		t.Errorf("expected the last update to be v3, got %q", updates[2].Value)
+	}
+	if c.Active() {
+		t.Error("expected Drain to cancel the subscription")
+	}
+}
+
+func TestEqualFunc(t *testing.T) {
+	var mu sync.Mutex
+	value := `{"a": 1}`
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write([]byte(value))
+	})
+	c.EqualFunc = func(old, new []byte) bool {
+		return strings.ReplaceAll(string(old), " ", "") == strings.ReplaceAll(string(new), " ", "")
+	}
+	ch := subscribe(t, c)
+	receive(t, ch)
+
+	mu.Lock()
+	value = `{"a":1}`
+	mu.Unlock()
+	c.Refresh()
+	select {
+	case update := <-ch:
+		t.Errorf("expected no update for an equal value, got %+v", update)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestValidate(t *testing.T) {
+	var mu sync.Mutex
+	value := `{"ok": true}`
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write([]byte(value))
+	})
+	c.Validate = func(value []byte) error {
+		if !json.Valid(value) {
+			return errors.New("not JSON")
+		}
+		return nil
+	}
+	ch := subscribe(t, c)
+	receive(t, ch)
+
+	mu.Lock()
+	value = `{"ok": tru`
+	mu.Unlock()
+	c.Refresh()
+	update := receive(t, ch)
+	var ve *viteset.ValidationError
+	if !errors.As(update.Error, &ve) {
+		t.Fatalf("expected a ValidationError, got %v", update.Error)
+	}
+	if current, _ := c.Value(); string(current) != `{"ok": true}` {
+		t.Errorf("expected the last valid value to be kept, got %q", current)
+	}
+}
+
+func TestRejectEmpty(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {})
+	c.RejectEmpty = true
+	update := receive(t, subscribe(t, c))
+	if !errors.Is(update.Error, viteset.ErrEmptyValue) {
+		t.Errorf("expected ErrEmptyValue, got %v", update.Error)
+	}
+	if _, ok := c.Value(); ok {
+		t.Error("expected the empty value not to be stored")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	var mu sync.Mutex
+	status := http.StatusOK
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.WriteHeader(status)
+		w.Write([]byte("hello"))
+	})
+	errs := make(chan error, 1)
+	c.OnError = func(err error) { errs <- err }
+	get, cancel, err := c.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+	for deadline := time.Now().Add(5 * time.Second); get() == nil; {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for a value")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if string(get()) != "hello" {
+		t.Errorf("expected hello, got %q", get())
+	}
+
+	mu.Lock()
+	status = http.StatusInternalServerError
+	mu.Unlock()
+	c.Refresh()
+	select {
+	case err := <-errs:
+		var se *viteset.ServerError
+		if !errors.As(err, &se) {
+			t.Errorf("expected a ServerError, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an error")
+	}
+	if string(get()) != "hello" {
+		t.Errorf("expected the last value to be kept, got %q", get())
+	}
+}
+
+func TestVersion(t *testing.T) {
+	v := viteset.Version()
+	if v.String() != viteset.VERSION {
+		t.Errorf("expected %s, got %s", viteset.VERSION, v)
+	}
+	if v.Compare(viteset.SemVer{Major: v.Major + 1}) != -1 {
+		t.Error("expected an older version to compare as older")
+	}
+	if v.Compare(v) != 0 {
+		t.Error("expected a version to equal itself")
+	}
+}
+
+func TestDisableHTTP2(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+
+	for _, disable := range []bool{false, true} {
+		c := &viteset.Client{Blob: "some-blob", Secret: "some-secret", Host: srv.URL,
+			TLSConfig: &tls.Config{RootCAs: roots}, DisableHTTP2: disable}
+		proto, err := c.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := map[bool]string{false: "HTTP/2.0", true: "HTTP/1.1"}[disable]; string(proto) != want {
+			t.Errorf("DisableHTTP2=%t: expected %s, got %s", disable, want, proto)
+		}
+	}
+}
+
+func TestGetMany(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("value of " + r.URL.Path[1:]))
+	}))
+	t.Cleanup(srv.Close)
+	m := &viteset.MultiClient{Secret: "some-secret", Blobs: []string{"a", "b", "missing"}, Host: srv.URL,
+		MaxConcurrency: 2}
+	values, err := m.GetMany()
+	var gme *viteset.GetManyError
+	if !errors.As(err, &gme) {
+		t.Fatalf("expected a GetManyError, got %v", err)
+	}
+	var nfe *viteset.NotFoundError
+	if !errors.As(gme.Errors["missing"], &nfe) {
+		t.Errorf("expected a NotFoundError for the missing blob, got %v", gme.Errors["missing"])
+	}
+	if len(values) != 2 || string(values["a"]) != "value of a" || string(values["b"]) != "value of b" {
+		t.Errorf("unexpected values: %q", values)
+	}
+}
+
+func TestAddListener(t *testing.T) {
+	var mu sync.Mutex
+	value := "first"
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write([]byte(value))
+	})
+	first, removeFirst, err := c.AddListener()
+	if err != nil {
+		t.Fatal(err)
+	}
+	receive(t, first)
+	second, removeSecond, err := c.AddListener()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer removeSecond()
+	if update := receive(t, second); string(update.Value) != "first" {
+		t.Errorf("expected a new listener to get the latest value, got %q", update.Value)
+	}
+
+	removeFirst()
+	if _, ok := <-first; ok {
+		t.Error("expected a removed listener's channel to close")
+	}
+	mu.Lock()
+	value = "second"
+	mu.Unlock()
+	c.Refresh()
+	if update := receive(t, second); string(update.Value) != "second" {
+		t.Errorf("expected the change to reach the remaining listener, got %q", update.Value)
+	}
+}
+
+func TestStatusErrorBody(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("<h1>Down for maintenance</h1>"))
+	})
+	_, err := c.Get()
+	var se *viteset.StatusError
+	if !errors.As(err, &se) {
+		t.Fatalf("expected a StatusError, got %v", err)
+	}
+	if string(se.Body) != "<h1>Down for maintenance</h1>" {
+		t.Errorf("expected the response body, got %q", se.Body)
+	}
+	if ct := se.Header.Get("Content-Type"); ct != "text/html" {
+		t.Errorf("expected the response headers, got Content-Type %q", ct)
+	}
+}
+
+// fakeClock is a Clock whose timers fire as soon as they're created, recording each requested delay. With hold set,
+// its timers never fire.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	delays []time.Duration
+	hold   bool
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) NewTimer(d time.Duration) viteset.Timer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.delays = append(f.delays, d)
+	t := fakeTimer(make(chan time.Time, 1))
+	if !f.hold {
+		f.now = f.now.Add(d)
+		t <- f.now
+	}
+	return t
+}
+
+// advance moves the clock forward by d.
+func (f *fakeClock) advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// delaysSoFar returns a copy of the delays requested so far.
+func (f *fakeClock) delaysSoFar() []time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]time.Duration(nil), f.delays...)
+}
+
+// fakeTimer is a Timer which has already fired, or never will.
+type fakeTimer chan time.Time
+
+func (t fakeTimer) C() <-chan time.Time { return t }
+func (t fakeTimer) Stop() bool          { return false }
+
+func TestClockBackoff(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	c.Clock = clock
+	ch := subscribe(t, c)
+	for i := 0; i < 4; i++ {
+		receive(t, ch)
+	}
+	c.Cancel()
+
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	want := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute}
+	for i, d := range want {
+		if clock.delays[i] != d {
+			t.Errorf("delay %d: expected %s, got %s", i, d, clock.delays[i])
+		}
+	}
+}
+
+func TestHasValue(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {})
+	if c.HasValue() {
+		t.Error("expected no value before subscribing")
+	}
+	if update := receive(t, subscribe(t, c)); update.Error != nil {
+		t.Fatal(update.Error)
+	}
+	if !c.HasValue() {
+		t.Error("expected an empty value to count as a value")
+	}
+}
+
+func TestDialContextUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "viteset.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+
+	c := &viteset.Client{Blob: "some-blob", Secret: "some-secret", Host: "http://viteset",
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}}
+	value, err := c.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != "hello" {
+		t.Errorf("expected hello, got %q", value)
+	}
+}
+
+func TestSubscribeFetchesImmediately(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	c.Interval = time.Hour
+	ch := subscribe(t, c)
+	select {
+	case update := <-ch:
+		if update.Error != nil {
+			t.Fatal(update.Error)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the first value without waiting for the interval")
+	}
+}
+
+func TestRefreshContext(t *testing.T) {
+	var mu sync.Mutex
+	value := "first"
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write([]byte(value))
+	})
+	ch := subscribe(t, c)
+	receive(t, ch)
+
+	mu.Lock()
+	value = "second"
+	mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	got, err := c.RefreshContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "second" {
+		t.Errorf("expected second, got %q", got)
+	}
+	if update := receive(t, ch); string(update.Value) != "second" {
+		t.Errorf("expected the change to be sent too, got %q", update.Value)
+	}
+}
+
+func TestCacheFile(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "cache")
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("hello"))
+	}
+	c := newServer(t, handler)
+	c.CacheFile = cacheFile
+	receive(t, subscribe(t, c))
+	c.Cancel()
+
+	restarted := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != `"v1"` {
+			t.Errorf("expected the cached ETag, got %q", r.Header.Get("If-None-Match"))
+		}
+		handler(w, r)
+	})
+	restarted.CacheFile = cacheFile
+	if update := receive(t, subscribe(t, restarted)); string(update.Value) != "hello" {
+		t.Errorf("expected the cached value, got %q", update.Value)
+	}
+}
+
+func TestCacheFileCorrupt(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "cache")
+	if err := ioutil.WriteFile(cacheFile, []byte("not gzip"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	c.CacheFile = cacheFile
+	if update := receive(t, subscribe(t, c)); string(update.Value) != "hello" {
+		t.Errorf("expected the fetched value, got %q", update.Value)
+	}
+}
+
+func TestSubscribeInvalidTimeout(t *testing.T) {
+	c := &viteset.Client{Blob: "some-blob", Secret: "some-secret", Timeout: -time.Second}
+	if _, err := c.Subscribe(); err == nil {
+		c.Cancel()
+		t.Error("expected an error for a negative timeout")
+	}
+}
+
+func TestSubscribeInvalidInterval(t *testing.T) {
+	for _, interval := range []time.Duration{-time.Second, time.Second, viteset.MIN_INTERVAL - 1} {
+		c := &viteset.Client{Blob: "some-blob", Secret: "some-secret", Interval: interval}
+		if _, err := c.Subscribe(); err == nil {
+			c.Cancel()
+			t.Errorf("%s: expected an error", interval)
+		}
+		if c.Active() {
+			t.Errorf("%s: expected no subscription", interval)
+		}
+	}
+}
+
+func TestSubscribeInvalidBackoff(t *testing.T) {
+	for name, c := range map[string]*viteset.Client{
+		"negative max backoff": {MaxBackoff: -time.Second},
+		"multiplier below 1":   {BackoffMultiplier: 0.5},
+		"negative multiplier":  {BackoffMultiplier: -2},
+	} {
+		c.Blob, c.Secret = "some-blob", "some-secret"
+		if _, err := c.Subscribe(); err == nil {
+			c.Cancel()
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestOnUpdatePanic(t *testing.T) {
+	var mu sync.Mutex
+	value := "first"
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write([]byte(value))
+	})
+	updates := make(chan viteset.Update, 10)
+	c.OnUpdate = func(update viteset.Update) {
+		updates <- update
+		if string(update.Value) == "first" {
+			panic("consumer bug")
+		}
+	}
+	subscribe(t, c)
+	receive(t, updates)
+	update := receive(t, updates)
+	var pe *viteset.PanicError
+	if !errors.As(update.Error, &pe) || pe.Value != "consumer bug" || pe.Callback != "OnUpdate" {
+		t.Fatalf("expected a PanicError, got %v", update.Error)
+	}
+
+	mu.Lock()
+	value = "second"
+	mu.Unlock()
+	c.Refresh()
+	if update := receive(t, updates); string(update.Value) != "second" {
+		t.Errorf("expected polling to continue after the panic, got %+v", update)
+	}
+}
+
+func TestGetContextCanceled(t *testing.T) {
+	release := make(chan struct{})
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	defer close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.GetContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestAcceptStatus(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNonAuthoritativeInfo)
+		w.Write([]byte("hello"))
+	})
+	if _, err := c.Get(); err == nil {
+		t.Error("expected 203 to be an error by default")
+	}
+	c.AcceptStatus = []int{http.StatusOK, http.StatusNonAuthoritativeInfo}
+	value, err := c.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != "hello" {
+		t.Errorf("expected hello, got %q", value)
+	}
+}
+
+func TestDisableKeepAlives(t *testing.T) {
+	var mu sync.Mutex
+	conns := 0
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	srv.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			conns++
+			mu.Unlock()
+		}
+	}
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	c := &viteset.Client{Blob: "some-blob", Secret: "some-secret", Host: srv.URL, DisableKeepAlives: true}
+	for i := 0; i < 3; i++ {
+		if _, err := c.Get(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if conns != 3 {
+		t.Errorf("expected a connection per request, got %d", conns)
+	}
+}
+
+func TestUpdateDuration(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("hello"))
+	})
+	if update := receive(t, subscribe(t, c)); update.Duration < 20*time.Millisecond {
+		t.Errorf("expected the fetch duration, got %s", update.Duration)
+	}
+}
+
+func TestStrictCaching(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	})
+	c.StrictCaching = true
+	update := receive(t, subscribe(t, c))
+	if update.StatusCode != http.StatusNotModified {
+		t.Errorf("expected an error for an unexpected 304, got %+v", update)
+	}
+}
+
+func TestJSONLogger(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello")
+	})
+	var buf strings.Builder
+	c.Logger = viteset.NewJSONLogger(&buf)
+	if _, err := c.Get(); err != nil {
+		t.Fatal(err)
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(buf.String()), &entry); err != nil {
+		t.Fatalf("expected a line of JSON, got %q: %s", buf.String(), err)
+	}
+	if entry["blob"] != c.Blob || entry["host"] != c.Host || entry["status"] != float64(200) ||
+		entry["cache_hit"] != false {
+		t.Errorf("unexpected log entry: %v", entry)
+	}
+	if _, ok := entry["duration_ms"]; !ok {
+		t.Errorf("expected a duration in the log entry: %v", entry)
+	}
+}
+
+func TestMultiClientSecrets(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want := "Bearer secret-for-" + r.URL.Path[1:]; r.Header.Get("Authorization") != want {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("value of " + r.URL.Path[1:]))
+	}))
+	t.Cleanup(srv.Close)
+	m := &viteset.MultiClient{Secret: "secret-for-b", Secrets: map[string]string{"a": "secret-for-a"},
+		Blobs: []string{"a", "b"}, Host: srv.URL}
+	values, err := m.GetMany()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(values["a"]) != "value of a" || string(values["b"]) != "value of b" {
+		t.Errorf("unexpected values: %q", values)
+	}
+}
+
+func TestInTickRetries(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if requests%3 != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("hello"))
+	})
+	c.InTickRetries = 2
+	c.InTickRetryDelay = 10 * time.Millisecond
+	update := receive(t, subscribe(t, c))
+	if update.Error != nil {
+		t.Fatalf("expected the retried errors to be held back, got %v", update.Error)
+	}
+	if string(update.Value) != "hello" {
+		t.Errorf("expected hello, got %q", update.Value)
+	}
+	if fetches := c.Stats().Fetches; fetches != 3 {
+		t.Errorf("expected 3 fetches within the first poll, got %d", fetches)
+	}
+}
+
+func TestListBlobs(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer some-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("page") == "2" {
+			w.Write([]byte(`["c"]`))
+			return
+		}
+		w.Header().Set("Link", `<`+srv.URL+`/?page=2>; rel="next"`)
+		w.Write([]byte(`["a", "b"]`))
+	}))
+	t.Cleanup(srv.Close)
+	c := &viteset.Client{Secret: "some-secret", Host: srv.URL}
+	blobs, err := c.ListBlobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(blobs, ",") != "a,b,c" {
+		t.Errorf("expected the blobs from both pages, got %q", blobs)
+	}
+}
+
+func TestListBlobsUnsupported(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	if _, err := c.ListBlobs(); !errors.Is(err, viteset.ErrListingUnsupported) {
+		t.Errorf("expected ErrListingUnsupported, got %v", err)
+	}
+}
+
+func TestSetIfUnchanged(t *testing.T) {
+	var mu sync.Mutex
+	version := 1
+	etag := `"v1"`
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method == http.MethodPut {
+			if r.Header.Get("If-Match") != etag {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+			version++
+			etag = fmt.Sprintf(`"v%d"`, version)
+			w.Header().Set("ETag", etag)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte("hello"))
+	})
+	if err := c.SetIfUnchanged([]byte("too soon")); err == nil {
+		t.Error("expected an error before the blob was fetched")
+	}
+	if _, err := c.WaitForValue(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetIfUnchanged([]byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetIfUnchanged([]byte("second")); err != nil {
+		t.Fatalf("expected a write to follow on from the last one, got %v", err)
+	}
+	if value, _ := c.Value(); string(value) != "second" || c.ETag() != `"v3"` {
+		t.Errorf("expected the written value to be cached with its ETag, got %q with %s", value, c.ETag())
+	}
+
+	// another writer changes the blob
+	mu.Lock()
+	etag = `"elsewhere"`
+	mu.Unlock()
+	var ce *viteset.ConflictError
+	if err := c.SetIfUnchanged([]byte("third")); !errors.As(err, &ce) {
+		t.Errorf("expected a ConflictError for a stale ETag, got %v", err)
+	}
+}
+
+// roundTripFunc is an http.RoundTripper which calls itself.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestHostNormalized(t *testing.T) {
+	for host, want := range map[string]string{
+		"api.viteset.com":           "https://api.viteset.com/some-blob",
+		"https://api.viteset.com/":  "https://api.viteset.com/some-blob",
+		"http://localhost:8080//":   "http://localhost:8080/some-blob",
+		"https://proxy/viteset/":    "https://proxy/viteset/some-blob",
+		"https://api.viteset.com":   "https://api.viteset.com/some-blob",
+		"localhost:8080":            "https://localhost:8080/some-blob",
+		"http://api.viteset.com/v1": "http://api.viteset.com/v1/some-blob",
+	} {
+		var got string
+		c := &viteset.Client{Blob: "some-blob", Secret: "some-secret", Host: host,
+			HTTPClient: &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				got = req.URL.String()
+				return &http.Response{StatusCode: http.StatusOK, Header: http.Header{},
+					Body: ioutil.NopCloser(strings.NewReader("hello")), Request: req}, nil
+			})}}
+		if _, err := c.Get(); err != nil {
+			t.Errorf("%s: %s", host, err)
+		}
+		if got != want {
+			t.Errorf("%s: expected a request to %s, got %s", host, want, got)
+		}
+	}
+}
+
+func TestHostInvalid(t *testing.T) {
+	for _, host := range []string{"ftp://api.viteset.com", "https://", "https://api.viteset.com/?x=1", "http://[::1"} {
+		c := &viteset.Client{Blob: "some-blob", Secret: "some-secret", Host: host}
+		if _, err := c.Subscribe(); err == nil {
+			c.Cancel()
+			t.Errorf("%s: expected an error", host)
+		}
+	}
+}
+
+func TestStartDelay(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	c.StartDelay = 200 * time.Millisecond
+	start := time.Now()
+	update := receive(t, subscribe(t, c))
+	if update.Error != nil {
+		t.Fatal(update.Error)
+	}
+	if elapsed := time.Since(start); elapsed < c.StartDelay {
+		t.Errorf("expected the first fetch to wait %s, got it after %s", c.StartDelay, elapsed)
+	}
+}
+
+func TestTypedClientFinalError(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	tc := &viteset.TypedClient[string]{Client: c}
+	for i := 0; i < 20; i++ {
+		ch, err := tc.Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the fatal error ends the subscription before the consumer reads it
+		waitInactive(t, c)
+		update, ok := <-ch
+		var ae *viteset.AuthError
+		if !ok || !errors.As(update.Error, &ae) {
+			t.Fatalf("expected the final AuthError to be delivered, got %+v (open: %t)", update, ok)
+		}
+	}
+}
+
+func TestMultiClientFinalError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	t.Cleanup(srv.Close)
+	for i := 0; i < 20; i++ {
+		m := &viteset.MultiClient{Secret: "some-secret", Blobs: []string{"a"}, Host: srv.URL}
+		ch, err := m.Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the fatal error ends the subscription before the consumer reads it
+		waitInactive(t, m)
+		update, ok := <-ch
+		var ae *viteset.AuthError
+		if !ok || !errors.As(update.Error, &ae) {
+			t.Fatalf("expected the final AuthError to be delivered, got %+v (open: %t)", update, ok)
+		}
+	}
+}
+
+func TestDebounceWindow(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		values := []string{"a", "b", "c"}
+		if requests <= len(values) {
+			w.Write([]byte(values[requests-1]))
+			return
+		}
+		w.Write([]byte("c"))
+	})
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	c.Clock = clock
+	c.DebounceWindow = 20 * time.Second
+	c.CompareBytes = true
+	ch := subscribe(t, c)
+	if update := receive(t, ch); string(update.Value) != "a" {
+		t.Fatalf("expected the initial value right away, got %+v", update)
+	}
+	if update := receive(t, ch); string(update.Value) != "c" {
+		t.Fatalf("expected only the settled value, got %+v", update)
+	}
+	c.Cancel()
+
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	want := []time.Duration{15 * time.Second, 20 * time.Second, 20 * time.Second}
+	for i, d := range want {
+		if clock.delays[i] != d {
+			t.Errorf("delay %d: expected %s, got %s", i, d, clock.delays[i])
+		}
+	}
+}
+
+func TestDebounceWindowBackoff(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		switch requests {
+		case 1:
+			w.Write([]byte("a"))
+		case 2:
+			w.Write([]byte("b"))
+		default:
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	c.Clock = clock
+	c.DebounceWindow = 20 * time.Second
+	ch := subscribe(t, c)
+	receive(t, ch)
+	// the first error comes while the change is pending; the second is sent after the delay which followed it
+	for i := 0; i < 2; i++ {
+		if update := receive(t, ch); update.Error == nil {
+			t.Fatalf("expected an error, got %+v", update)
+		}
+	}
+	c.Cancel()
+
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	if clock.delays[2] != time.Hour {
+		t.Errorf("expected the failed debounce check to obey Retry-After, got a delay of %s", clock.delays[2])
+	}
+}
+
+func TestDebounceWindowInvalid(t *testing.T) {
+	c := &viteset.Client{Blob: "some-blob", Secret: "some-secret", DebounceWindow: 10 * time.Millisecond}
+	if _, err := c.Subscribe(); err == nil {
+		c.Cancel()
+		t.Error("expected an error for a debounce window below MIN_INTERVAL")
+	}
+}
+
+// zeroSource is a rand.Source which always returns 0, so jitter is always its most negative.
+type zeroSource struct{}
+
+func (zeroSource) Int63() int64 { return 0 }
+func (zeroSource) Seed(int64)   {}
+
+func TestJitter(t *testing.T) {
+	for jitter, want := range map[time.Duration]time.Duration{
+		10 * time.Second: 50 * time.Second,
+		50 * time.Second: viteset.MIN_INTERVAL,
+	} {
+		c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("hello"))
+		})
+		clock := &fakeClock{now: time.Unix(0, 0)}
+		c.Clock = clock
+		c.RandSource = zeroSource{}
+		c.Heartbeat = true
+		c.Interval = time.Minute
+		c.Jitter = jitter
+		ch := subscribe(t, c)
+		receive(t, ch)
+		receive(t, ch)
+		c.Cancel()
+
+		clock.mu.Lock()
+		if clock.delays[0] != want {
+			t.Errorf("jitter %s: expected a delay of %s, got %s", jitter, want, clock.delays[0])
+		}
+		clock.mu.Unlock()
+	}
+}
+
+func TestJitterInvalid(t *testing.T) {
+	c := &viteset.Client{Blob: "some-blob", Secret: "some-secret", Jitter: time.Hour}
+	if _, err := c.Subscribe(); err == nil {
+		c.Cancel()
+		t.Error("expected an error for jitter longer than the interval")
+	}
+}
+
+func TestInTickRetriesRetryAfter(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	c.InTickRetries = 2
+	c.InTickRetryDelay = 10 * time.Millisecond
+	if update := receive(t, subscribe(t, c)); update.Error == nil {
+		t.Fatalf("expected an error, got %+v", update)
+	}
+	if fetches := c.Stats().Fetches; fetches != 1 {
+		t.Errorf("expected no retries after a Retry-After, got %d fetches", fetches)
+	}
+}
+
+func TestListBlobsCrossOriginLink(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("expected no request to another host, got one with Authorization %q", r.Header.Get("Authorization"))
+		w.Write([]byte(`[]`))
+	}))
+	t.Cleanup(other.Close)
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", `<`+other.URL+`/?page=2>; rel="next"`)
+		w.Write([]byte(`["a"]`))
+	})
+	if _, err := c.ListBlobs(); err == nil {
+		t.Error("expected an error for a next page link to another host")
+	}
+}
+
+func TestOnErrorPanic(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	c.ChannelBuffer = 2
+	c.OnError = func(err error) {
+		panic("consumer bug")
+	}
+	ch := subscribe(t, c)
+	var se *viteset.ServerError
+	if update := receive(t, ch); !errors.As(update.Error, &se) {
+		t.Fatalf("expected the ServerError first, got %v", update.Error)
+	}
+	var pe *viteset.PanicError
+	if update := receive(t, ch); !errors.As(update.Error, &pe) || pe.Value != "consumer bug" || pe.Callback != "OnError" ||
+		pe.Error() != "OnError panicked: consumer bug" {
+		t.Errorf("expected a PanicError, got %v", update.Error)
+	}
+}
+
+func TestInitialRetriesRetryAfter(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	c.Clock = clock
+	c.InitialRetries = 3
+	receive(t, subscribe(t, c))
+	c.Cancel()
+
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	for i, d := range clock.delays[:3] {
+		if d != 2*time.Minute {
+			t.Errorf("retry %d: expected a delay of 2m0s from Retry-After, got %s", i, d)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	start := time.Unix(1e9, 0)
+	for header, want := range map[string]time.Duration{
+		"90": 90 * time.Second,
+		start.Add(10 * time.Minute).UTC().Format(http.TimeFormat): 10 * time.Minute,
+	} {
+		header := header
+		c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", header)
+			w.WriteHeader(http.StatusTooManyRequests)
+		})
+		clock := &fakeClock{now: start}
+		c.Clock = clock
+		ch := subscribe(t, c)
+		receive(t, ch)
+		receive(t, ch)
+		c.Cancel()
+		if d := clock.delaysSoFar()[0]; d != want {
+			t.Errorf("Retry-After %q: expected a delay of %s, got %s", header, want, d)
+		}
+	}
+}
+
+func TestSetIntervalReschedules(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	clock := &fakeClock{now: time.Unix(0, 0), hold: true}
+	c.Clock = clock
+	receive(t, subscribe(t, c))
+	if err := c.SetInterval(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(clock.delaysSoFar()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the poll to be rescheduled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if delays := clock.delaysSoFar(); delays[0] != 15*time.Second || delays[1] != time.Hour {
+		t.Errorf("expected the next poll to move from 15s to 1h, got %v", delays)
+	}
+	if err := c.SetInterval(time.Second); err == nil {
+		t.Error("expected an error for an interval below MIN_INTERVAL")
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	c.Clock = &fakeClock{now: time.Unix(0, 0)}
+	c.Heartbeat = true
+	ch := subscribe(t, c)
+	receive(t, ch)
+	c.Pause()
+	if !c.Paused() {
+		t.Fatal("expected the subscription to be paused")
+	}
+	// let a poll which was already underway finish
+	for i := 0; i < 2; i++ {
+		time.Sleep(20 * time.Millisecond)
+		for len(ch) > 0 {
+			<-ch
+		}
+	}
+	fetches := c.Stats().Fetches
+	time.Sleep(50 * time.Millisecond)
+	if got := c.Stats().Fetches; got != fetches {
+		t.Errorf("expected no fetches while paused, got %d more", got-fetches)
+	}
+
+	c.Resume()
+	if c.Paused() {
+		t.Error("expected the subscription to be resumed")
+	}
+	if update := receive(t, ch); update.Error != nil {
+		t.Fatal(update.Error)
+	}
+}
+
+func TestDropStale(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		fmt.Fprint(w, requests)
+	})
+	c.Clock = &fakeClock{now: time.Unix(0, 0)}
+	c.DropStale = true
+	ch := subscribe(t, c)
+	// the consumer falls behind while the Client keeps polling
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Stats().Fetches < 5 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected polling to continue while the channel was full")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if update := receive(t, ch); string(update.Value) == "1" {
+		t.Error("expected the stale first value to be dropped")
+	}
+}
+
+func TestExpectContentType(t *testing.T) {
+	for contentType, ok := range map[string]bool{
+		"application/json":                true,
+		"application/json; charset=utf-8": true,
+		"text/html":                       false,
+	} {
+		contentType := contentType
+		c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.Write([]byte("{}"))
+		})
+		c.ExpectContentType = "application/json"
+		_, err := c.Get()
+		var cte *viteset.ContentTypeError
+		if ok && err != nil {
+			t.Errorf("%s: expected success, got %v", contentType, err)
+		}
+		if !ok && (!errors.As(err, &cte) || cte.Got != contentType) {
+			t.Errorf("%s: expected a ContentTypeError, got %v", contentType, err)
+		}
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	for size, ok := range map[int]bool{16: true, 17: false} {
+		body := strings.Repeat("x", size)
+		c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		})
+		c.MaxBodySize = 16
+		_, err := c.Get()
+		var btle *viteset.BodyTooLargeError
+		if ok && err != nil {
+			t.Errorf("%d bytes: expected success, got %v", size, err)
+		}
+		if !ok && (!errors.As(err, &btle) || btle.Limit != 16) {
+			t.Errorf("%d bytes: expected a BodyTooLargeError, got %v", size, err)
+		}
+	}
+}
+
+func TestHeadersPrecedence(t *testing.T) {
+	requests := make(chan http.Header, 1)
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests <- r.Header.Clone()
+		w.Write([]byte("hello"))
+	})
+	c.Headers = http.Header{
+		"X-Tenant":      {"acme"},
+		"Authorization": {"Bearer not-the-secret"},
+		"If-None-Match": {`"bogus"`},
+		"User-Agent":    {"impostor"},
+	}
+	if _, err := c.Get(); err != nil {
+		t.Fatal(err)
+	}
+	got := <-requests
+	if got.Get("X-Tenant") != "acme" {
+		t.Errorf("expected the extra header to be sent, got %q", got.Get("X-Tenant"))
+	}
+	if got.Get("Authorization") != "Bearer some-secret" {
+		t.Errorf("expected Headers not to override Authorization, got %q", got.Get("Authorization"))
+	}
+	if got.Get("If-None-Match") != "" {
+		t.Errorf("expected Headers not to add If-None-Match, got %q", got.Get("If-None-Match"))
+	}
+	if strings.Contains(got.Get("User-Agent"), "impostor") {
+		t.Errorf("expected Headers not to override User-Agent, got %q", got.Get("User-Agent"))
+	}
+}
+
+func TestAuthHeader(t *testing.T) {
+	tests := []struct {
+		header, scheme string
+		authorize      func(req *http.Request)
+		wantHeader     string
+		want           string
+	}{
+		{wantHeader: "Authorization", want: "Bearer some-secret"},
+		{scheme: "Token", wantHeader: "Authorization", want: "Token some-secret"},
+		{header: "X-Api-Key", wantHeader: "X-Api-Key", want: "some-secret"},
+		{header: "X-Api-Key", scheme: "Key", wantHeader: "X-Api-Key", want: "Key some-secret"},
+		{authorize: func(req *http.Request) { req.SetBasicAuth("user", "pass") }, wantHeader: "Authorization",
+			want: "Basic dXNlcjpwYXNz"},
+	}
+	for _, tt := range tests {
+		got := make(chan string, 1)
+		wantHeader := tt.wantHeader
+		c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+			got <- r.Header.Get(wantHeader)
+		})
+		c.AuthHeader, c.AuthScheme, c.Authorize = tt.header, tt.scheme, tt.authorize
+		if _, err := c.Get(); err != nil {
+			t.Fatal(err)
+		}
+		if value := <-got; value != tt.want {
+			t.Errorf("header %q, scheme %q: expected %s: %q, got %q", tt.header, tt.scheme, wantHeader, tt.want, value)
+		}
+	}
+}
+
+// waitClosed drains ch until it closes, failing the test if it doesn't close in time.
+func waitClosed(t *testing.T, name string, ch <-chan viteset.Update) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("%s: channel never closed", name)
+		}
+	}
+}
+
+func TestAddListenerCancelWhileNotReading(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	c.Clock = &fakeClock{now: time.Unix(0, 0)}
+	c.Heartbeat = true
+	reading, _, err := c.AddListener()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stuck, _, err := c.AddListener()
+	if err != nil {
+		t.Fatal(err)
+	}
+	receive(t, reading)
+	// the heartbeats soon fill stuck's buffer, leaving the broadcast waiting on it
+	time.Sleep(50 * time.Millisecond)
+	c.Cancel()
+	waitClosed(t, "reading listener", reading)
+	waitClosed(t, "stuck listener", stuck)
+}
+
+func TestRegistryCancelWhileNotReading(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	c.Clock = &fakeClock{now: time.Unix(0, 0)}
+	c.Heartbeat = true
+	r := &viteset.Registry{}
+	reading, cancelReading, err := r.Subscribe(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancelReading()
+	stuck, cancelStuck, err := r.Subscribe(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancelStuck()
+	receive(t, reading)
+	time.Sleep(50 * time.Millisecond)
+	c.Cancel()
+	waitClosed(t, "reading subscription", reading)
+	waitClosed(t, "stuck subscription", stuck)
+}
+
+func TestRefreshContextCanceled(t *testing.T) {
+	var mu sync.Mutex
+	slow := false
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		wait := slow
+		mu.Unlock()
+		if wait {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}
+		w.Write([]byte("hello"))
+	})
+	clock := &fakeClock{now: time.Unix(0, 0), hold: true}
+	c.Clock = clock
+	ch := subscribe(t, c)
+	receive(t, ch)
+
+	mu.Lock()
+	slow = true
+	mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.RefreshContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the refresh to hit its deadline, got %v", err)
+	}
+	select {
+	case update := <-ch:
+		t.Errorf("expected the caller's deadline not to be sent as an Update, got %+v", update)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// rescheduling shows whether the refresh counted as a failure, which would back off the next poll
+	if err := c.SetInterval(viteset.MIN_INTERVAL); err != nil {
+		t.Fatal(err)
+	}
+	// the poller waits again after the initial poll, the refresh, and the reschedule
+	deadline := time.Now().Add(5 * time.Second)
+	for len(clock.delaysSoFar()) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the poll to be rescheduled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if d := clock.delaysSoFar()[2]; d != viteset.MIN_INTERVAL {
+		t.Errorf("expected no backoff after the canceled refresh, got a delay of %s", d)
+	}
+}
+
+func TestSetIntervalWhileGetting(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	receive(t, subscribe(t, c))
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				if err := c.SetInterval(viteset.MIN_INTERVAL + time.Duration(i)*time.Second); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				if _, err := c.Get(); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestInTickRetriesSlowServer(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		// hang until the Client gives up
+		<-r.Context().Done()
+	})
+	c.Timeout = time.Second
+	c.InTickRetries = 10
+	c.InTickRetryDelay = 10 * time.Millisecond
+	start := time.Now()
+	if update := receive(t, subscribe(t, c)); update.Error == nil {
+		t.Fatalf("expected an error, got %+v", update)
+	}
+	// the retries must fit in a quarter of the interval, though each attempt could take up to Timeout
+	if elapsed, limit := time.Since(start), viteset.MIN_INTERVAL/4+500*time.Millisecond; elapsed > limit {
+		t.Errorf("expected the retries to stop within %s, took %s", limit, elapsed)
+	}
+}
+
+func TestMultiClientCancelWhileSubscribing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	t.Cleanup(srv.Close)
+	m := &viteset.MultiClient{Secret: "some-secret", Blobs: []string{"a", "b"}, Host: srv.URL}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			m.Cancel()
+			m.Active()
+		}
+	}()
+	ch, err := m.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	m.Cancel()
+	waitClosed(t, "MultiClient", ch)
+}
+
+func TestLastSuccessHealthy(t *testing.T) {
+	var mu sync.Mutex
+	status := http.StatusOK
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.WriteHeader(status)
+	})
+	setStatus := func(s int) {
+		mu.Lock()
+		defer mu.Unlock()
+		status = s
+	}
+	start := time.Unix(1e9, 0)
+	clock := &fakeClock{now: start, hold: true}
+	c.Clock = clock
+	if last, ok := c.LastSuccess(); ok || !last.IsZero() {
+		t.Errorf("expected no last success before fetching, got %s", last)
+	}
+
+	c.Get()
+	if last, ok := c.LastSuccess(); !ok || !last.Equal(start) {
+		t.Errorf("expected a 200 to set the last success to %s, got %s", start, last)
+	}
+	clock.advance(time.Minute)
+	setStatus(http.StatusNotModified)
+	c.Get()
+	if last, _ := c.LastSuccess(); !last.Equal(start.Add(time.Minute)) {
+		t.Errorf("expected a 304 to refresh the last success, got %s", last)
+	}
+	clock.advance(time.Minute)
+	setStatus(http.StatusInternalServerError)
+	c.Get()
+	if last, _ := c.LastSuccess(); !last.Equal(start.Add(time.Minute)) {
+		t.Errorf("expected an error to leave the last success alone, got %s", last)
+	}
+
+	if !c.Healthy(90 * time.Second) {
+		t.Error("expected the Client to be healthy a minute after its last success")
+	}
+	clock.advance(31 * time.Second)
+	if c.Healthy(90 * time.Second) {
+		t.Error("expected the Client to be unhealthy once its last success is older than maxAge")
+	}
+}
+
+// ctxKey is the type of context values set by tests.
+type ctxKey string
+
+func TestWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey("trace"), "some-trace"))
+	defer cancel()
+	seen := make(chan interface{}, 100)
+	c, err := viteset.NewClient("some-blob", "some-secret", viteset.WithContext(ctx),
+		viteset.WithHTTPClient(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			seen <- req.Context().Value(ctxKey("trace"))
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{},
+				Body: ioutil.NopCloser(strings.NewReader("hello")), Request: req}, nil
+		})}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := subscribe(t, c)
+	if update := receive(t, ch); update.Error != nil {
+		t.Fatal(update.Error)
+	}
+	if got := <-seen; got != "some-trace" {
+		t.Errorf("expected the base context's value on the request, got %v", got)
+	}
+
+	cancel()
+	waitClosed(t, "updates", ch)
+	waitInactive(t, c)
+}
+
+func TestUpdateFetchedAt(t *testing.T) {
+	var mu sync.Mutex
+	status := http.StatusOK
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.WriteHeader(status)
+		w.Write([]byte("hello"))
+	})
+	start := time.Unix(1e9, 0)
+	clock := &fakeClock{now: start, hold: true}
+	c.Clock = clock
+	ch := subscribe(t, c)
+	update := receive(t, ch)
+	if update.Error != nil {
+		t.Fatal(update.Error)
+	}
+	if !update.FetchedAt.Equal(start) {
+		t.Errorf("expected a value Update fetched at %s, got %s", start, update.FetchedAt)
+	}
+
+	mu.Lock()
+	status = http.StatusInternalServerError
+	mu.Unlock()
+	clock.advance(time.Minute)
+	c.Refresh()
+	update = receive(t, ch)
+	if update.Error == nil {
+		t.Fatal("expected an error Update")
+	}
+	if want := start.Add(time.Minute); !update.FetchedAt.Equal(want) {
+		t.Errorf("expected an error Update fetched at %s, got %s", want, update.FetchedAt)
+	}
+}
+
+func TestDialTimeout(t *testing.T) {
+	// a listener which never accepts stops completing connections once its backlog is full
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	var conns []net.Conn
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	for full := false; !full; {
+		conn, err := net.DialTimeout("tcp", ln.Addr().String(), 100*time.Millisecond)
+		var ne net.Error
+		switch {
+		case errors.As(err, &ne) && ne.Timeout():
+			full = true
+		case err != nil || len(conns) > 10000:
+			t.Skipf("could not fill the listener's backlog: %v", err)
+		default:
+			conns = append(conns, conn)
+		}
+	}
+
+	c := &viteset.Client{Blob: "some-blob", Secret: "some-secret", Host: "http://" + ln.Addr().String(),
+		Timeout: 10 * time.Second, DialTimeout: 200 * time.Millisecond}
+	start := time.Now()
+	if _, err := c.Get(); err == nil {
+		t.Fatal("expected an error connecting to a server which never completes the connection")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected DialTimeout to end the connection attempt, but it took %s", elapsed)
+	}
+}
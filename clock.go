@@ -0,0 +1,58 @@
+package client
+
+import "time"
+
+// Clock is a source of time for a Client, which tests can replace to control polling schedules without real sleeps.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// NewTimer creates a Timer which fires once d has passed.
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer fires once, like a time.Timer.
+type Timer interface {
+	// C returns the channel which receives the time when the Timer fires.
+	C() <-chan time.Time
+
+	// Stop prevents the Timer from firing. It returns False if the Timer already fired or was stopped.
+	Stop() bool
+}
+
+// realClock is the Clock based on the system time.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+// realTimer is the Timer of realClock.
+type realTimer struct {
+	t *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t realTimer) Stop() bool {
+	return t.t.Stop()
+}
+
+// clock returns the Client's Clock.
+func (c *Client) clock() Clock {
+	if c.Clock != nil {
+		return c.Clock
+	}
+	return realClock{}
+}
+
+// now returns the current time according to the Client's Clock.
+func (c *Client) now() time.Time {
+	return c.clock().Now()
+}
@@ -0,0 +1,248 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StatusError is returned when the Viteset API responds with an unexpected HTTP status code.
+//
+// Failures with well-known status codes are returned as an AuthError, NotFoundError, or ServerError instead, each of
+// which unwraps to its StatusError.
+type StatusError struct {
+	// The HTTP status code of the response
+	StatusCode int
+
+	// The raw body of the response, decompressed, for consumers which need to inspect or display it, such as a
+	// server-provided maintenance page
+	Body []byte
+
+	// The headers of the response, such as the Content-Type of Body
+	Header http.Header
+
+	// The error message from the body, if the body is a JSON error envelope like `{"error": "blob not found"}`
+	Message string
+
+	// The machine-readable error code from the body, if the body is a JSON error envelope which includes a "code"
+	Code string
+
+	// How long the server asked the Client to wait before polling again, from the Retry-After header of a 429 or 503
+	// response. The Client delays its next poll accordingly. This is 0 if the server didn't ask.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("expected status code %d but got %d: `%s`", http.StatusOK, e.StatusCode, e.Body)
+}
+
+// AuthError is returned when the Viteset API rejects the Client's Secret (401 or 403).
+type AuthError struct {
+	StatusError
+}
+
+func (e *AuthError) Unwrap() error {
+	return &e.StatusError
+}
+
+// NotFoundError is returned when the blob does not exist (404).
+type NotFoundError struct {
+	StatusError
+}
+
+func (e *NotFoundError) Unwrap() error {
+	return &e.StatusError
+}
+
+// ConflictError is returned by SetIfUnchanged when the blob changed since the Client last fetched it, so the write
+// was rejected (412). Fetch the blob again to get its new value and ETag, then retry the write.
+type ConflictError struct {
+	StatusError
+}
+
+func (e *ConflictError) Unwrap() error {
+	return &e.StatusError
+}
+
+// ServerError is returned when the Viteset API fails to handle the request (5xx).
+type ServerError struct {
+	StatusError
+}
+
+func (e *ServerError) Unwrap() error {
+	return &e.StatusError
+}
+
+// NetworkError is returned when the request could not be completed, such as when the host is unreachable or the
+// connection drops before the response body is read.
+type NetworkError struct {
+	// The underlying transport error
+	Err error
+}
+
+func (e *NetworkError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// ContentTypeError is returned when a fetch succeeds but the response's Content-Type doesn't match the Client's
+// ExpectContentType.
+type ContentTypeError struct {
+	// The expected media type
+	Expected string
+
+	// The Content-Type header the server responded with
+	Got string
+}
+
+func (e *ContentTypeError) Error() string {
+	return fmt.Sprintf("expected content type %s but got `%s`", e.Expected, e.Got)
+}
+
+// BodyTooLargeError is returned when a response body is larger than the Client's MaxBodySize.
+type BodyTooLargeError struct {
+	// The largest allowed body size, in bytes
+	Limit int64
+}
+
+func (e *BodyTooLargeError) Error() string {
+	return fmt.Sprintf("response body exceeds the limit of %d bytes", e.Limit)
+}
+
+// ErrEmptyValue is wrapped by the ValidationError sent when a fetch returns an empty value and the Client's
+// RejectEmpty is set.
+var ErrEmptyValue = errors.New("value is empty")
+
+// ValidationError is sent when a fetched value fails the Client's Validate function, or is empty and RejectEmpty is
+// set. The Client keeps the last valid value rather than replacing it.
+type ValidationError struct {
+	// The error returned by Validate
+	Err error
+
+	// The rejected value
+	Value []byte
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid blob value: %s", e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// CircuitOpenError is sent when a subscription's fetches have failed MaxConsecutiveErrors times in a row, so the
+// Client stops polling for BreakerCooldown before trying again.
+type CircuitOpenError struct {
+	// The number of consecutive failed fetches
+	Failures int
+
+	// How long the Client waits before its next attempt
+	Cooldown time.Duration
+
+	// The error from the last failed fetch
+	Err error
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker open after %d consecutive failures, retrying in %s: %s", e.Failures, e.Cooldown,
+		e.Err)
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return e.Err
+}
+
+// PanicError is sent when the Client's OnUpdate or OnError callback panics. The Client recovers and keeps polling.
+type PanicError struct {
+	// The name of the callback which panicked, OnUpdate or OnError
+	Callback string
+
+	// The value passed to panic
+	Value interface{}
+
+	// The stack trace of the goroutine when it panicked
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%s panicked: %v", e.Callback, e.Value)
+}
+
+// GetManyError is returned by MultiClient.GetMany when some of the blobs couldn't be fetched.
+type GetManyError struct {
+	// The error for each blob which couldn't be fetched
+	Errors map[string]error
+}
+
+func (e *GetManyError) Error() string {
+	blobs := make([]string, 0, len(e.Errors))
+	for blob := range e.Errors {
+		blobs = append(blobs, blob)
+	}
+	sort.Strings(blobs)
+	msgs := make([]string, len(blobs))
+	for i, blob := range blobs {
+		msgs[i] = fmt.Sprintf("%s: %s", blob, e.Errors[blob])
+	}
+	return fmt.Sprintf("failed to fetch %d blobs: %s", len(blobs), strings.Join(msgs, "; "))
+}
+
+// errorEnvelope is the shape of the JSON body the Viteset API responds with on errors.
+type errorEnvelope struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// newStatusError returns the most specific error type for a response with an unexpected status code. now is the
+// time the response arrived, for parsing a Retry-After date.
+func newStatusError(resp *http.Response, body []byte, now time.Time) error {
+	statusCode := resp.StatusCode
+	se := StatusError{StatusCode: statusCode, Body: body, Header: resp.Header}
+	var envelope errorEnvelope
+	if json.Unmarshal(body, &envelope) == nil {
+		se.Message = envelope.Error
+		se.Code = envelope.Code
+	}
+	if statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable {
+		se.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), now)
+	}
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return &AuthError{se}
+	case statusCode == http.StatusNotFound:
+		return &NotFoundError{se}
+	case statusCode == http.StatusPreconditionFailed:
+		return &ConflictError{se}
+	case statusCode >= 500 && statusCode <= 599:
+		return &ServerError{se}
+	default:
+		return &se
+	}
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is either a number of seconds or an HTTP date,
+// into how long to wait after now. It returns 0 if the value is missing, invalid, or in the past.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
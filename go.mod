@@ -1,3 +1,3 @@
 module github.com/mplewis/viteset-client-go
 
-go 1.16
+go 1.18
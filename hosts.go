@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"errors"
+)
+
+// fetch retrieves the latest value for the blob from the current healthy host, failing over to the others in turn.
+func (c *Client) fetch(ctx context.Context, last version) (same bool, data []byte, ver version, err error) {
+	hosts := c.hosts()
+	start := c.startHost(len(hosts))
+	for i := 0; ; i++ {
+		index := (start + i) % len(hosts)
+		same, data, ver, err = c.fetchFrom(ctx, hosts[index], last)
+		if err == nil {
+			c.hostHealthy(index)
+			return same, data, ver, err
+		}
+		if i == len(hosts)-1 || ctx.Err() != nil || !failover(err) {
+			return same, data, ver, err
+		}
+		c.hostFailed(index)
+	}
+}
+
+// failover returns True if err means that another host might succeed where this one failed.
+func failover(err error) bool {
+	var ne *NetworkError
+	var se *ServerError
+	return errors.As(err, &ne) || errors.As(err, &se)
+}
+
+// hosts returns the hosts to fetch from, in order of preference.
+func (c *Client) hosts() []string {
+	if len(c.Hosts) > 0 {
+		return c.Hosts
+	}
+	return []string{c.Host}
+}
+
+// currentHost returns the host which most recently served a successful fetch.
+func (c *Client) currentHost() string {
+	hosts := c.hosts()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return hosts[c.hostIndex%len(hosts)]
+}
+
+// startHost returns the index of the host the next fetch should try first.
+func (c *Client) startHost(n int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.hostIndex != 0 && !c.now().Before(c.primaryRetryAt) {
+		// give the preferred host another chance
+		return 0
+	}
+	return c.hostIndex % n
+}
+
+// hostHealthy records that the host at index served a successful fetch.
+func (c *Client) hostHealthy(index int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hostIndex = index
+	if index == 0 {
+		c.primaryFailures = 0
+	}
+}
+
+// hostFailed records that the host at index failed, backing off before the first host is tried again.
+func (c *Client) hostFailed(index int) {
+	if index != 0 {
+		return
+	}
+	c.mu.Lock()
+	failures := c.primaryFailures
+	c.primaryFailures++
+	c.mu.Unlock()
+
+	retryAt := c.now().Add(c.delay(failures))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.primaryRetryAt = retryAt
+}
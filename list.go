@@ -0,0 +1,118 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrListingUnsupported is returned by ListBlobs when the server has no endpoint for listing blobs, so callers can
+// fall back to the blob names they were configured with.
+var ErrListingUnsupported = errors.New("server does not support listing blobs")
+
+// ListBlobs returns the names of the blobs the Client's Secret can access, such as for checking at startup that
+// its blob is accessible. Blob need not be set.
+//
+// The listing is fetched from the root of the host as a JSON array of blob names. If the server paginates the
+// listing with a Link header with rel="next", ListBlobs follows it and returns the names from every page. Links to
+// a different scheme or host are rejected with an error rather than followed with the Secret. If the server has no
+// listing endpoint, the error wraps ErrListingUnsupported.
+func (c *Client) ListBlobs() ([]string, error) {
+	return c.ListBlobsContext(c.baseContext())
+}
+
+// ListBlobsContext works like ListBlobs, but aborts the requests if ctx is canceled.
+func (c *Client) ListBlobsContext(ctx context.Context) ([]string, error) {
+	if err := c.setupClient(); err != nil {
+		return nil, err
+	}
+
+	var blobs []string
+	next := c.currentHost() + "/"
+	seen := map[string]bool{}
+	for next != "" && !seen[next] {
+		seen[next] = true
+		page, link, err := c.listPage(ctx, next)
+		if err != nil {
+			return nil, err
+		}
+		blobs = append(blobs, page...)
+		next = link
+	}
+	return blobs, nil
+}
+
+// listPage fetches one page of the listing from target, returning its blob names and the URL of the next page, or ""
+// if it's the last.
+func (c *Client) listPage(ctx context.Context, target string) (blobs []string, next string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+	req, err := c.newRequestURL(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, "", &NetworkError{Err: err}
+	}
+	defer closeBody(resp)
+	body, err := c.readBody(resp)
+	if err != nil {
+		return nil, "", err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return nil, "", fmt.Errorf("%w: got status code %d", ErrListingUnsupported, resp.StatusCode)
+	default:
+		return nil, "", newStatusError(resp, body, c.now())
+	}
+	if err := json.Unmarshal(body, &blobs); err != nil {
+		return nil, "", fmt.Errorf("%w: expected a JSON array of blob names: %s", ErrListingUnsupported, err)
+	}
+
+	if link := nextLink(resp.Header.Values("Link")); link != "" {
+		ref, err := url.Parse(link)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid next page link %q: %w", link, err)
+		}
+		u := req.URL.ResolveReference(ref)
+		if u.Scheme != req.URL.Scheme || u.Host != req.URL.Host {
+			// following it would send the Secret to another origin
+			return nil, "", fmt.Errorf("next page link %q points to a different host", link)
+		}
+		next = u.String()
+	}
+	return blobs, next, nil
+}
+
+// nextLink returns the target of the rel="next" link in the given Link header values, or "" if there is none.
+func nextLink(values []string) string {
+	for _, value := range values {
+		for _, link := range strings.Split(value, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range parts[1:] {
+				name, rel, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || !strings.EqualFold(name, "rel") {
+					continue
+				}
+				for _, r := range strings.Fields(strings.Trim(rel, `"`)) {
+					if strings.EqualFold(r, "next") {
+						return target[1 : len(target)-1]
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Logger receives a structured entry for each fetch a Client makes. Use NewTextLogger or NewJSONLogger to write
+// entries to an io.Writer, or implement Logger to pass the fields to a logging library of your choice.
+//
+// Log is called on the goroutine that made the fetch, so it should return quickly.
+type Logger interface {
+	Log(entry LogEntry)
+}
+
+// LogEntry describes a single completed fetch for logging.
+type LogEntry struct {
+	// When the fetch completed
+	Time time.Time
+
+	// The name of the blob which was fetched
+	Blob string
+
+	// The host the blob was fetched from
+	Host string
+
+	// The HTTP status code of the response, or 0 if no response was received
+	Status int
+
+	// How long the fetch took, including reading the response body
+	Duration time.Duration
+
+	// True if the server responded 304 Not Modified, so the cached copy of the blob is still current
+	CacheHit bool
+
+	// The error that caused the fetch to fail, or nil if it succeeded
+	Error error
+}
+
+// String renders the entry as a single line of plain text, as written by NewTextLogger.
+func (e LogEntry) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s viteset: fetch blob=%s host=%s status=%d duration=%s cache_hit=%t",
+		e.Time.Format(time.RFC3339), e.Blob, e.Host, e.Status, e.Duration, e.CacheHit)
+	if e.Error != nil {
+		fmt.Fprintf(&b, " error=%q", e.Error.Error())
+	}
+	return b.String()
+}
+
+// MarshalJSON renders the entry as a JSON object with snake_case keys, as written by NewJSONLogger. The duration is
+// in milliseconds.
+func (e LogEntry) MarshalJSON() ([]byte, error) {
+	out := struct {
+		Time     time.Time `json:"time"`
+		Blob     string    `json:"blob"`
+		Host     string    `json:"host"`
+		Status   int       `json:"status"`
+		Duration float64   `json:"duration_ms"`
+		CacheHit bool      `json:"cache_hit"`
+		Error    string    `json:"error,omitempty"`
+	}{e.Time, e.Blob, e.Host, e.Status, float64(e.Duration) / float64(time.Millisecond), e.CacheHit, ""}
+	if e.Error != nil {
+		out.Error = e.Error.Error()
+	}
+	return json.Marshal(out)
+}
+
+// writerLogger writes each entry to an io.Writer on its own line.
+type writerLogger struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format func(LogEntry) []byte
+}
+
+// NewTextLogger returns a Logger which writes each entry to w as a line of plain text.
+func NewTextLogger(w io.Writer) Logger {
+	return &writerLogger{w: w, format: func(e LogEntry) []byte { return []byte(e.String()) }}
+}
+
+// NewJSONLogger returns a Logger which writes each entry to w as a line of JSON, for log aggregation pipelines.
+func NewJSONLogger(w io.Writer) Logger {
+	return &writerLogger{w: w, format: func(e LogEntry) []byte {
+		data, _ := json.Marshal(e)
+		return data
+	}}
+}
+
+// Log writes the entry to the writer, ignoring write errors.
+func (l *writerLogger) Log(entry LogEntry) {
+	line := append(l.format(entry), '\n')
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.w.Write(line)
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"net/http"
+	"time"
+)
+
+// Metrics receives a report after each fetch a Client makes, so callers can record poll counts, error counts,
+// latency, and bytes transferred in the metrics system of their choice.
+//
+// OnFetch is called on the goroutine that made the fetch, so it should return quickly.
+type Metrics interface {
+	OnFetch(info FetchInfo)
+}
+
+// Tracer traces each fetch a Client makes, such as with a span in a distributed tracing system.
+type Tracer interface {
+	// StartFetch is called before each fetch request is sent. It returns the request to send in its place, which may
+	// carry a new context or extra headers for propagating the trace, and a function to call once the fetch completes.
+	StartFetch(req *http.Request) (*http.Request, func(info FetchInfo))
+}
+
+// FetchInfo describes a single completed fetch.
+type FetchInfo struct {
+	// The name of the blob which was fetched
+	Blob string
+
+	// How long the fetch took, including reading the response body
+	Duration time.Duration
+
+	// The HTTP status code of the response, or 0 if no response was received
+	StatusCode int
+
+	// The size of the response body in bytes
+	Bytes int
+
+	// True if the server responded 304 Not Modified because the blob has not changed since the last fetch
+	NotModified bool
+
+	// The error that caused the fetch to fail, or nil if it succeeded
+	Error error
+}
+
+// Stats holds cumulative counters describing a Client's fetches since it was created.
+type Stats struct {
+	// The number of fetches attempted, whether they succeeded or failed
+	Fetches int64
+
+	// The number of fetches which succeeded, including those which found the blob unchanged
+	Successes int64
+
+	// The number of fetches which found the blob unchanged, with a 304 Not Modified response
+	NotModified int64
+
+	// The number of fetches which failed
+	Errors int64
+
+	// The total size of the response bodies read, in bytes
+	Bytes int64
+
+	// When the Client last retrieved a new value for the blob, or the zero time if it never has
+	LastChange time.Time
+}
+
+// record adds a completed fetch to the stats.
+func (s *Stats) record(info FetchInfo) {
+	s.Fetches++
+	s.Bytes += int64(info.Bytes)
+	switch {
+	case info.Error != nil:
+		s.Errors++
+	case info.NotModified:
+		s.Successes++
+		s.NotModified++
+	default:
+		s.Successes++
+	}
+}
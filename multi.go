@@ -0,0 +1,217 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// MultiClient watches several blobs on the same host, sending updates for all of them over a single
+// channel. Each Update's Blob field names the blob it is for.
+//
+// Each blob is fetched immediately on Subscribe. After that, polls are staggered evenly across the interval so that
+// the blobs aren't all fetched at once.
+type MultiClient struct {
+	// The secret for a client with access to all of the specified blobs, except those with their own secret in Secrets
+	Secret string
+
+	// Optional: Secrets for individual blobs, keyed by blob name, for setups where each blob has its own scoped
+	// client. Blobs not in Secrets use Secret. Default is to use Secret for every blob.
+	Secrets map[string]string
+
+	// The names of the blobs to subscribe to
+	Blobs []string
+
+	// Optional: The update polling interval for each blob. See Client.Interval.
+	Interval time.Duration
+
+	// Optional: The hostname of the Viteset API. See Client.Host.
+	Host string
+
+	// Optional: The time limit for each individual fetch. See Client.Timeout.
+	Timeout time.Duration
+
+	// Optional: The HTTP client used to make requests. See Client.HTTPClient.
+	HTTPClient *http.Client
+
+	// Optional: Called with each blob's Client before subscribing, to configure any other Client settings.
+	Configure func(c *Client)
+
+	// Optional: The most blobs GetMany fetches at once. Default is 4.
+	MaxConcurrency int
+
+	// Guards clients
+	mu sync.Mutex
+
+	// The Clients for each blob
+	clients []*Client
+}
+
+var _ Subscriber = (*MultiClient)(nil)
+
+// Subscribe starts watching all of the blobs for changes. It returns a channel and an error.
+//
+// If any blob's subscription is invalid, no blobs are subscribed and the error is returned.
+func (m *MultiClient) Subscribe() (<-chan Update, error) {
+	return m.SubscribeContext(context.Background())
+}
+
+// SubscribeContext works like Subscribe, but ties the subscriptions to the lifetime of ctx. When ctx is canceled,
+// the MultiClient stops polling and closes the Update channel.
+func (m *MultiClient) SubscribeContext(ctx context.Context) (<-chan Update, error) {
+	// held until the subscriptions have started, so a concurrent Cancel cancels all of them
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.active() {
+		return nil, errors.New("client subscription is already active")
+	}
+	if len(m.Blobs) == 0 {
+		return nil, errors.New("missing blob names")
+	}
+
+	clients := make([]*Client, len(m.Blobs))
+	for i, blob := range m.Blobs {
+		c, err := m.newClient(blob)
+		if err != nil {
+			return nil, err
+		}
+		c.stagger = c.Interval * time.Duration(i) / time.Duration(len(m.Blobs))
+		clients[i] = c
+	}
+
+	ch := make(chan Update)
+	var wg sync.WaitGroup
+	for _, c := range clients {
+		c.seed()
+		updates, err := c.start(ctx, true)
+		if err != nil {
+			// each Client is new, so it can't already be subscribed
+			panic(err)
+		}
+		canceled := c.cancellation()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for update := range updates {
+				select {
+				case ch <- update:
+				case <-canceled:
+					// the subscription was canceled while the consumer wasn't reading
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(ch)
+	}()
+
+	m.clients = clients
+	return ch, nil
+}
+
+// newClient creates and validates the Client for one of the blobs.
+func (m *MultiClient) newClient(blob string) (*Client, error) {
+	secret, ok := m.Secrets[blob]
+	if !ok {
+		secret = m.Secret
+	}
+	c := &Client{
+		Secret:     secret,
+		Blob:       blob,
+		Interval:   m.Interval,
+		Host:       m.Host,
+		Timeout:    m.Timeout,
+		HTTPClient: m.HTTPClient,
+	}
+	if m.Configure != nil {
+		m.Configure(c)
+	}
+	if err := c.setup(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetMany fetches the current value of every blob once, without subscribing to updates. Blobs are fetched
+// concurrently, up to MaxConcurrency at a time. It returns the values of the blobs which were fetched successfully,
+// and if any failed, a GetManyError holding each failed blob's error.
+func (m *MultiClient) GetMany() (map[string][]byte, error) {
+	if len(m.Blobs) == 0 {
+		return nil, errors.New("missing blob names")
+	}
+	if m.MaxConcurrency < 0 {
+		return nil, errors.New("max concurrency must not be negative")
+	}
+	clients := make([]*Client, len(m.Blobs))
+	for i, blob := range m.Blobs {
+		c, err := m.newClient(blob)
+		if err != nil {
+			return nil, err
+		}
+		clients[i] = c
+	}
+
+	limit := m.MaxConcurrency
+	if limit == 0 {
+		limit = DEFAULT_MAX_CONCURRENCY
+	}
+	sem := make(chan struct{}, limit)
+	var mu sync.Mutex
+	values := map[string][]byte{}
+	errs := map[string]error{}
+	var wg sync.WaitGroup
+	for _, c := range clients {
+		c := c
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			value, err := c.Get()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs[c.Blob] = err
+			} else {
+				values[c.Blob] = value
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return values, &GetManyError{Errors: errs}
+	}
+	return values, nil
+}
+
+// Cancel cancels the subscriptions for all blobs and closes the Update channel.
+func (m *MultiClient) Cancel() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.clients {
+		c.Cancel()
+	}
+}
+
+// Active returns True if any of this MultiClient's blob subscriptions is active and False otherwise.
+func (m *MultiClient) Active() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.active()
+}
+
+// active implements Active. The caller must hold m.mu.
+func (m *MultiClient) active() bool {
+	for _, c := range m.clients {
+		if c.Active() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// An Option configures a Client created with NewClient.
+type Option func(*Client)
+
+// NewClient creates a Client for the given blob and secret, validating its configuration up front rather than when
+// Subscribe is called.
+//
+// Constructing a Client struct directly is still supported.
+func NewClient(blob, secret string, opts ...Option) (*Client, error) {
+	c := &Client{Blob: blob, Secret: secret}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if err := c.setup(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// WithInterval sets the update polling interval.
+func WithInterval(interval time.Duration) Option {
+	return func(c *Client) {
+		c.Interval = interval
+	}
+}
+
+// WithHost sets the hostname of the Viteset API.
+func WithHost(host string) Option {
+	return func(c *Client) {
+		c.Host = host
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to make requests.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.HTTPClient = httpClient
+	}
+}
+
+// WithTimeout sets the time limit for each individual fetch.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.Timeout = timeout
+	}
+}
+
+// WithBufferSize sets the number of Updates which can queue in a subscription's channel.
+func WithBufferSize(n int) Option {
+	return func(c *Client) {
+		c.ChannelBuffer = n
+	}
+}
+
+// WithContext sets the context for requests made by methods which don't take one, such as Subscribe, Get, and Set.
+// Its deadline, cancellation, and values, such as trace IDs, apply to every request those methods make. A deadline or
+// cancellation ends a subscription started by Subscribe as a whole, not just each of its requests.
+func WithContext(ctx context.Context) Option {
+	return func(c *Client) {
+		c.baseCtx = ctx
+	}
+}
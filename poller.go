@@ -0,0 +1,518 @@
+package client
+
+import (
+	"context"
+	"math/rand"
+	"runtime/debug"
+	"time"
+)
+
+// poller runs the polling loop for a single subscription, sending Updates over ch until ctx is canceled.
+type poller struct {
+	c   *Client
+	ctx context.Context
+	ch  chan Update
+
+	// Signals a requested refresh; see Client.Refresh
+	refresh chan struct{}
+
+	// Receives refreshes which wait for their result; see Client.RefreshContext
+	calls chan refreshCall
+
+	// The refresh call which wait received
+	call refreshCall
+
+	// The error from the last poll, or nil if it succeeded
+	err error
+
+	// How long the last poll's fetch took
+	duration time.Duration
+
+	// Signals a changed interval; see Client.SetInterval
+	reschedule chan struct{}
+
+	// The number of consecutive failed fetches
+	failures int
+
+	// True once a value has been delivered to the consumer of this subscription
+	sent bool
+
+	// True once an error has been sent since the last successful fetch
+	reported bool
+
+	// True if the next Update carrying a value should be marked Recovered
+	recovering bool
+
+	// True while the circuit breaker is open after MaxConsecutiveErrors failures
+	open bool
+
+	// True once this subscription has sent DefaultValue
+	fellBack bool
+
+	// A changed value which is being held until the blob stops changing, with DebounceWindow
+	pending *Update
+
+	// Extra delay before the first scheduled poll
+	stagger time.Duration
+
+	// The earliest time the server allows the next poll, from a Retry-After header
+	retryAt time.Time
+
+	// The source of randomness for jitter
+	rand *rand.Rand
+}
+
+// The reasons wait can return.
+type waitResult int
+
+const (
+	// The next scheduled poll is due
+	waitDue waitResult = iota
+
+	// A refresh was requested before the next scheduled poll
+	waitRefresh
+
+	// A refresh which waits for its result was requested, and is held in call
+	waitCall
+
+	// The polling interval changed, so the next poll must be rescheduled
+	waitReschedule
+
+	// The subscription ended
+	waitDone
+)
+
+// run polls the blob until the subscription ends. If initialPoll is False, the first poll waits for the interval to
+// pass.
+func (p *poller) run(initialPoll bool) {
+	if initialPoll && p.c.StartDelay > 0 {
+		start := p.c.now().Add(p.c.StartDelay)
+		for waiting := true; waiting; {
+			switch p.wait(start) {
+			case waitDone:
+				return
+			case waitCall:
+				// answering the call makes the first poll
+				if !p.answer(p.call) {
+					return
+				}
+				initialPoll = false
+				waiting = false
+			case waitReschedule:
+				// interval changes don't move the first poll
+			default:
+				waiting = false
+			}
+		}
+	}
+	for {
+		// the first poll must not wait for the interval, which may be long, so consumers get a value right away
+		if initialPoll && !p.poll() {
+			return
+		}
+		initialPoll = true
+		next := p.next()
+		p.stagger = 0
+		for due := false; !due; {
+			switch p.wait(next) {
+			case waitDone:
+				return
+			case waitRefresh:
+				// refreshes don't move the next scheduled poll, unless they found a change to debounce
+				if !p.poll() {
+					return
+				}
+				if p.pending != nil {
+					next = p.next()
+				}
+			case waitCall:
+				if !p.answer(p.call) {
+					return
+				}
+				if p.pending != nil {
+					next = p.next()
+				}
+			case waitReschedule:
+				next = p.next()
+			case waitDue:
+				due = true
+			}
+		}
+	}
+}
+
+// next returns when the next scheduled poll should happen, starting from now.
+func (p *poller) next() time.Time {
+	if p.pending != nil && p.failures == 0 {
+		// check back soon to see whether the pending change has settled, unless the server asked us to hold off
+		next := p.c.now().Add(p.c.DebounceWindow)
+		if next.Before(p.retryAt) {
+			next = p.retryAt
+		}
+		return next
+	}
+	if p.retrying() {
+		next := p.c.now().Add(p.c.retryDelay(p.failures))
+		if next.Before(p.retryAt) {
+			// quick retries still obey the server
+			next = p.retryAt
+		}
+		return next
+	}
+	if p.open {
+		// probe once the cooldown is over
+		next := p.c.now().Add(p.c.BreakerCooldown)
+		if next.Before(p.retryAt) {
+			next = p.retryAt
+		}
+		return next
+	}
+	failures := p.failures
+	if !p.sent && failures > p.c.InitialRetries {
+		// the quick retries don't count toward backoff
+		failures -= p.c.InitialRetries
+	}
+	d := p.c.delay(failures) + p.stagger + p.jitter()
+	if d < MIN_INTERVAL {
+		// jitter must not get around the minimum interval
+		d = MIN_INTERVAL
+	}
+	next := p.c.now().Add(d)
+	if next.Before(p.retryAt) {
+		// the server asked us to hold off
+		next = p.retryAt
+	}
+	return next
+}
+
+// retrying returns True if the initial fetch has failed and quick retries remain.
+func (p *poller) retrying() bool {
+	return !p.sent && p.failures > 0 && p.failures <= p.c.InitialRetries
+}
+
+// jitter returns a random adjustment to the delay before the next poll, between -Jitter and +Jitter.
+func (p *poller) jitter() time.Duration {
+	if p.c.Jitter <= 0 {
+		return 0
+	}
+	return time.Duration(p.rand.Int63n(int64(2*p.c.Jitter)+1)) - p.c.Jitter
+}
+
+// poll fetches the blob once and sends an Update if its value changed or the fetch failed. It returns False if the
+// subscription should end.
+func (p *poller) poll() bool {
+	return p.pollContext(p.ctx)
+}
+
+// pollContext works like poll, but bounds the fetch with ctx, which must end when p.ctx does. If ctx ends first, the
+// error is left in p.err without counting as a failed poll or sending an Update.
+func (p *poller) pollContext(ctx context.Context) bool {
+	_, last := p.c.cached()
+	same, data, ver, err := p.fetch(ctx, last)
+	fetchedAt := p.c.now()
+	if err == nil && !same {
+		err = p.c.validate(data)
+	}
+	p.err = err
+	if err != nil && ctx.Err() != nil && p.ctx.Err() == nil {
+		// a refresh's own context ended, which is the caller's business rather than a failure of the subscription
+		return true
+	}
+	if err != nil {
+		return p.failed(err, fetchedAt)
+	}
+	p.failures = 0
+	p.open = false
+	if p.reported {
+		p.reported = false
+		p.recovering = true
+	}
+	if same {
+		return p.unchanged(last, fetchedAt)
+	}
+	return p.changed(data, ver, fetchedAt)
+}
+
+// fetch retrieves the latest value for the blob, retrying transient failures within this poll up to InTickRetries
+// times. Retries, including their fetches, only happen within a quarter of the Interval from the start of the poll,
+// and a retry still running at the end of that window is abandoned, so they never push back the next poll. Errors
+// with a Retry-After aren't retried, since the server asked us to hold off.
+func (p *poller) fetch(ctx context.Context, last version) (same bool, data []byte, ver version, err error) {
+	p.c.mu.Lock()
+	deadline := p.c.now().Add(p.c.Interval / 4)
+	p.c.mu.Unlock()
+
+	same, data, ver, err = p.attempt(ctx, last)
+	d := p.c.InTickRetryDelay
+	for i := 0; i < p.c.InTickRetries && retryable(err); i++ {
+		wait := d/2 + time.Duration(p.rand.Int63n(int64(d)+1))
+		remaining := deadline.Sub(p.c.now()) - wait
+		if remaining <= 0 {
+			break
+		}
+		d *= 2
+
+		timer := p.c.clock().NewTimer(wait)
+		select {
+		case <-timer.C():
+		case <-ctx.Done():
+			timer.Stop()
+			return same, data, ver, err
+		}
+
+		retryCtx, cancel := context.WithTimeout(ctx, remaining)
+		rSame, rData, rVer, rErr := p.attempt(retryCtx, last)
+		cut := rErr != nil && retryCtx.Err() != nil && ctx.Err() == nil
+		cancel()
+		if cut {
+			// the retry ran out of time, so report the failure which prompted it
+			break
+		}
+		same, data, ver, err = rSame, rData, rVer, rErr
+	}
+	return same, data, ver, err
+}
+
+// attempt makes a single fetch for fetch, recording its duration.
+func (p *poller) attempt(ctx context.Context, last version) (same bool, data []byte, ver version, err error) {
+	start := p.c.now()
+	same, data, ver, err = p.c.fetch(ctx, last)
+	p.duration = p.c.now().Sub(start)
+	return same, data, ver, err
+}
+
+// retryable returns True if err is a transient failure which fetch may retry within the same poll.
+func retryable(err error) bool {
+	return err != nil && failover(err) && retryAfter(err) == 0
+}
+
+// failed handles a failed fetch.
+func (p *poller) failed(err error, fetchedAt time.Time) bool {
+	p.failures++
+	if d := retryAfter(err); d > 0 {
+		p.retryAt = p.c.now().Add(d)
+	}
+	if p.retrying() && !p.c.fatal(err) {
+		// hold the error back until the quick retries run out
+		return true
+	}
+	u := Update{Error: err, StatusCode: statusCode(err), FetchedAt: fetchedAt, Duration: p.duration}
+	if p.c.MaxConsecutiveErrors > 0 && p.failures >= p.c.MaxConsecutiveErrors && !p.c.fatal(err) {
+		if p.open {
+			// the breaker-open Update already reported the outage
+			return true
+		}
+		p.open = true
+		u.Error = &CircuitOpenError{Failures: p.failures, Cooldown: p.c.BreakerCooldown, Err: err}
+	}
+	p.reported = true
+	if !p.send(u) {
+		return false
+	}
+	if !p.fallback(fetchedAt) {
+		return false
+	}
+	// retrying a fatal error will never succeed, so stop polling
+	return !p.c.fatal(err)
+}
+
+// fallback sends DefaultValue if this subscription has nothing else to offer yet. It returns False if the
+// subscription ended while sending.
+func (p *poller) fallback(fetchedAt time.Time) bool {
+	if p.sent || p.fellBack || p.c.DefaultValue == nil {
+		return true
+	}
+	if _, ok := p.c.Value(); ok {
+		// a cached value is better than a default
+		return true
+	}
+	p.fellBack = true
+	return p.send(Update{Value: p.c.DefaultValue, FetchedAt: fetchedAt, Fallback: true, Initial: true})
+}
+
+// unchanged handles a fetch which found the blob unchanged since the last value.
+func (p *poller) unchanged(last version, fetchedAt time.Time) bool {
+	if p.pending != nil {
+		// the debounced change has settled
+		u := *p.pending
+		p.pending = nil
+		return p.deliver(u)
+	}
+
+	value, ok := p.c.Value()
+	if !ok {
+		return true
+	}
+	u := Update{Value: value, ETag: last.etag, FetchedAt: fetchedAt, Duration: p.duration}
+	if !p.sent {
+		// this subscription hasn't delivered a value yet, such as when the cache was seeded from InitialValue or a
+		// Client is reused after Cancel, so send the cached value the server just confirmed is current
+		return p.deliver(u)
+	}
+	if p.c.Heartbeat || p.recovering {
+		// fetches are succeeding again, which is worth reporting even though the blob is unchanged
+		u.Unchanged = true
+		return p.send(u)
+	}
+	return true
+}
+
+// changed handles a fetch which found a new value for the blob.
+func (p *poller) changed(data []byte, ver version, fetchedAt time.Time) bool {
+	if p.c.CompareBytes || p.c.EqualFunc != nil {
+		if last, ok := p.c.Value(); ok && p.c.equal(last, data) {
+			// only the version changed
+			p.c.revalidate(ver)
+			return p.unchanged(ver, fetchedAt)
+		}
+	}
+	p.c.store(data, ver)
+	u := Update{Value: data, ETag: ver.etag, FetchedAt: fetchedAt, Duration: p.duration}
+	if p.sent && p.c.DebounceWindow > 0 {
+		// hold the change until the blob stops changing
+		p.pending = &u
+		return true
+	}
+	return p.deliver(u)
+}
+
+// deliver sends an Update carrying a value to the consumer.
+func (p *poller) deliver(u Update) bool {
+	u.Initial = !p.sent && !p.fellBack
+	if !p.send(u) {
+		return false
+	}
+	p.sent = true
+	return true
+}
+
+// send delivers an update to OnUpdate or the channel, giving up if the subscription ends while the consumer isn't
+// reading. With DropStale, it makes room in a full channel by discarding the oldest queued update instead of waiting.
+// It returns False if the subscription ended.
+func (p *poller) send(u Update) bool {
+	u.Blob = p.c.Blob
+	if u.Error == nil && p.recovering {
+		u.Recovered = true
+		p.recovering = false
+	}
+	if p.ctx.Err() != nil {
+		// don't race a cancellation which already happened
+		return false
+	}
+	var onErrorPanic error
+	if u.Error != nil && p.c.OnError != nil {
+		onErrorPanic = recoverPanic("OnError", func() { p.c.OnError(u.Error) })
+	}
+	if !p.emit(u) {
+		return false
+	}
+	if onErrorPanic != nil {
+		// report the panic as an Update of its own, without calling OnError again
+		return p.emit(Update{Error: onErrorPanic, Blob: u.Blob, FetchedAt: u.FetchedAt})
+	}
+	return true
+}
+
+// emit implements send, delivering u to OnUpdate or the channel.
+func (p *poller) emit(u Update) bool {
+	if p.c.OnUpdate != nil {
+		if err := p.callOnUpdate(u); err != nil {
+			// report the panic to the callback, giving up if it panics again
+			_ = p.callOnUpdate(Update{Error: err, Blob: u.Blob, FetchedAt: u.FetchedAt})
+		}
+		return p.ctx.Err() == nil
+	}
+	for p.c.DropStale {
+		select {
+		case p.ch <- u:
+			return true
+		case <-p.ctx.Done():
+			return false
+		default:
+		}
+		select {
+		case <-p.ch:
+			// discarded a stale update
+		default:
+			// the consumer made room first
+		}
+	}
+	select {
+	case p.ch <- u:
+		return true
+	case <-p.ctx.Done():
+		return false
+	}
+}
+
+// callOnUpdate calls OnUpdate with u, returning a PanicError if it panics.
+func (p *poller) callOnUpdate(u Update) error {
+	return recoverPanic("OnUpdate", func() { p.c.OnUpdate(u) })
+}
+
+// recoverPanic calls f, the user callback named callback, returning a PanicError if it panics.
+func recoverPanic(callback string, f func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Callback: callback, Value: r, Stack: debug.Stack()}
+		}
+	}()
+	f()
+	return nil
+}
+
+// answer polls for a refresh call and replies with the result. It returns False if the subscription should end.
+func (p *poller) answer(call refreshCall) bool {
+	ctx, cancel := context.WithCancel(call.ctx)
+	defer cancel()
+	go func() {
+		// the fetch must also stop if the subscription ends
+		select {
+		case <-p.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ok := p.pollContext(ctx)
+	result := refreshResult{err: p.err}
+	if p.err == nil {
+		result.value, _ = p.c.Value()
+	}
+	call.result <- result
+	return ok
+}
+
+// wait blocks until the next poll is due at next, a refresh is requested, or the subscription ends. While the
+// subscription is paused, scheduled polls are held until it resumes.
+func (p *poller) wait(next time.Time) waitResult {
+	timer := p.c.clock().NewTimer(next.Sub(p.c.now()))
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+	case <-p.refresh:
+		return waitRefresh
+	case p.call = <-p.calls:
+		return waitCall
+	case <-p.reschedule:
+		return waitReschedule
+	case <-p.ctx.Done():
+		return waitDone
+	}
+
+	p.c.mu.Lock()
+	resume := p.c.resume
+	p.c.mu.Unlock()
+	if resume != nil {
+		select {
+		case <-resume:
+		case <-p.refresh:
+			return waitRefresh
+		case p.call = <-p.calls:
+			return waitCall
+		case <-p.ctx.Done():
+			return waitDone
+		}
+	}
+	return waitDue
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// DefaultRegistry is a Registry shared by the whole process.
+var DefaultRegistry = &Registry{}
+
+// Registry dedupes subscriptions to the same blob within a process. Subscriptions with the same host, blob, and
+// secret share a single poller, which fans each Update out to all of them. Polling stops once every subscription to
+// a blob has been canceled.
+//
+// The zero value is ready to use.
+type Registry struct {
+	mu sync.Mutex
+
+	// The shared pollers, by the blob they poll
+	sources map[sourceKey]*source
+}
+
+// sourceKey identifies the blobs which can share a poller.
+type sourceKey struct {
+	host   string
+	blob   string
+	secret string
+}
+
+// source is a poller shared by several subscriptions.
+type source struct {
+	client  *Client
+	updates *broadcast
+	refs    int
+}
+
+// Subscribe subscribes to the blob c is configured for, sharing the poller of any existing subscription to the same
+// blob. It returns the Update channel and a function which cancels this subscription.
+//
+// When a poller is already running, c is only used to identify the blob, and the existing poller's settings apply.
+// Subscriptions which join a running poller immediately receive the last value it sent, if any.
+func (r *Registry) Subscribe(c *Client) (<-chan Update, func(), error) {
+	if err := c.setup(); err != nil {
+		return nil, nil, err
+	}
+	key := sourceKey{host: strings.Join(c.hosts(), " "), blob: c.Blob, secret: c.Secret}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	src, ok := r.sources[key]
+	if !ok {
+		if c.OnUpdate != nil {
+			return nil, nil, errors.New("shared subscriptions can't use OnUpdate")
+		}
+		ch, err := c.Subscribe()
+		if err != nil {
+			return nil, nil, err
+		}
+		src = &source{client: c, updates: newBroadcast(c.ChannelBuffer, c.cancellation())}
+		if r.sources == nil {
+			r.sources = map[sourceKey]*source{}
+		}
+		r.sources[key] = src
+		go r.pump(key, src, ch)
+	}
+
+	src.refs++
+	l := src.updates.add()
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() { r.release(key, src, l) })
+	}
+	return l.ch, cancel, nil
+}
+
+// pump fans updates out from a shared poller until it stops, such as after a fatal error.
+func (r *Registry) pump(key sourceKey, src *source, ch <-chan Update) {
+	for u := range ch {
+		src.updates.send(u)
+	}
+	src.updates.close()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sources[key] == src {
+		delete(r.sources, key)
+	}
+}
+
+// release removes a subscription from a shared poller, stopping the poller if it was the last one.
+func (r *Registry) release(key sourceKey, src *source, l *listener) {
+	src.updates.remove(l)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	src.refs--
+	if src.refs == 0 {
+		if r.sources[key] == src {
+			delete(r.sources, key)
+		}
+		src.client.Cancel()
+	}
+}
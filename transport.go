@@ -0,0 +1,45 @@
+package client
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+// baseTransport creates the HTTP transport which Clients' transports are based on.
+func baseTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = dialer(DEFAULT_DIAL_TIMEOUT).DialContext
+	return t
+}
+
+// dialer creates a dialer which gives up on connecting after timeout.
+func dialer(timeout time.Duration) *net.Dialer {
+	return &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+}
+
+// transportConfigured returns True if any of the Client's fields require an HTTP transport of its own.
+func (c *Client) transportConfigured() bool {
+	return c.TLSConfig != nil || c.DialTimeout != DEFAULT_DIAL_TIMEOUT || c.DisableHTTP2 || c.DisableKeepAlives ||
+		c.DialContext != nil
+}
+
+// newTransport creates an HTTP transport configured by the Client's fields.
+func (c *Client) newTransport() *http.Transport {
+	t := baseTransport()
+	if c.TLSConfig != nil {
+		t.TLSClientConfig = c.TLSConfig.Clone()
+	}
+	t.DialContext = dialer(c.DialTimeout).DialContext
+	if c.DialContext != nil {
+		t.DialContext = c.DialContext
+	}
+	t.DisableKeepAlives = c.DisableKeepAlives
+	if c.DisableHTTP2 {
+		// a non-nil, empty map turns off the transport's HTTP/2 support
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return t
+}
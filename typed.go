@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// TypedClient wraps a Client and decodes each blob value into a T before delivering it, so consumers don't need to
+// parse Update.Value themselves.
+//
+//     tc := TypedClient[MyConfig]{Client: &Client{Blob: "SOME_BLOB_NAME", Secret: "SOME_CLIENT_SECRET"}}
+//     updates, err := tc.Subscribe()
+type TypedClient[T any] struct {
+	// The Client used to fetch the blob
+	Client *Client
+
+	// Optional: The function used to decode blob values, such as yaml.Unmarshal. Default is json.Unmarshal.
+	Decode func(data []byte, v any) error
+}
+
+// TypedUpdate contains either a blob's latest decoded value, or an error that occurred during the last fetch or
+// while decoding the value. You must check update.Error before reading the value.
+type TypedUpdate[T any] struct {
+	Value T
+	Error error
+
+	// The HTTP status code of a failed fetch, or 0. See Update.StatusCode.
+	StatusCode int
+}
+
+// Subscribe starts watching the blob for changes, like Client.Subscribe, and decodes each value.
+func (tc *TypedClient[T]) Subscribe() (<-chan TypedUpdate[T], error) {
+	return tc.SubscribeContext(tc.Client.baseContext())
+}
+
+// SubscribeContext starts watching the blob for changes, like Client.SubscribeContext, and decodes each value.
+func (tc *TypedClient[T]) SubscribeContext(ctx context.Context) (<-chan TypedUpdate[T], error) {
+	updates, err := tc.Client.SubscribeContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan TypedUpdate[T])
+	canceled := tc.Client.cancellation()
+	go func() {
+		defer close(ch)
+		for update := range updates {
+			select {
+			case ch <- tc.decode(update):
+			case <-canceled:
+				// the subscription was canceled while the consumer wasn't reading
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// Cancel cancels the subscription of the underlying Client.
+func (tc *TypedClient[T]) Cancel() {
+	tc.Client.Cancel()
+}
+
+// decode converts an Update into a TypedUpdate, reporting decoding failures as the update's error.
+func (tc *TypedClient[T]) decode(update Update) TypedUpdate[T] {
+	if update.Error != nil {
+		return TypedUpdate[T]{Error: update.Error, StatusCode: update.StatusCode}
+	}
+	decode := tc.Decode
+	if decode == nil {
+		decode = json.Unmarshal
+	}
+	var value T
+	if err := decode(update.Value, &value); err != nil {
+		return TypedUpdate[T]{Error: err}
+	}
+	return TypedUpdate[T]{Value: value}
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SemVer is a semantic version number.
+type SemVer struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// Version returns the package version, parsed from VERSION.
+func Version() SemVer {
+	v, err := parseSemVer(VERSION)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// String formats the version as MAJOR.MINOR.PATCH.
+func (v SemVer) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Compare returns -1 if v is older than other, 1 if it is newer, and 0 if they are the same version.
+func (v SemVer) Compare(other SemVer) int {
+	for _, d := range []int{v.Major - other.Major, v.Minor - other.Minor, v.Patch - other.Patch} {
+		switch {
+		case d < 0:
+			return -1
+		case d > 0:
+			return 1
+		}
+	}
+	return 0
+}
+
+// parseSemVer parses a version number of the form MAJOR.MINOR.PATCH.
+func parseSemVer(s string) (SemVer, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return SemVer{}, fmt.Errorf("invalid version %q", s)
+	}
+	var nums [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return SemVer{}, fmt.Errorf("invalid version %q", s)
+		}
+		nums[i] = n
+	}
+	return SemVer{Major: nums[0], Minor: nums[1], Patch: nums[2]}, nil
+}
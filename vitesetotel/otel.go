@@ -0,0 +1,77 @@
+// Package vitesetotel traces Viteset client fetches with OpenTelemetry.
+//
+// Set a Client's Tracer to record a span for each fetch:
+//
+//     c := client.Client{
+//         Blob:   "SOME_BLOB_NAME",
+//         Secret: "SOME_CLIENT_SECRET",
+//         Tracer: vitesetotel.New(),
+//     }
+//
+// Each span is a child of the span carried by the context passed to SubscribeContext, so cancellation and trace
+// context flow from your app into every poll. The trace context is also propagated to the server in the request
+// headers.
+//
+// vitesetotel is a separate module, so the core client doesn't depend on OpenTelemetry or its minimum Go version.
+package vitesetotel
+
+import (
+	"net/http"
+
+	client "github.com/mplewis/viteset-client-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The name of the instrumentation library, used to name the tracer.
+const instrumentationName = "github.com/mplewis/viteset-client-go/vitesetotel"
+
+// Tracer implements client.Tracer with OpenTelemetry spans.
+type Tracer struct {
+	// Optional: The provider of the tracer used to record spans. Default is the global TracerProvider.
+	TracerProvider trace.TracerProvider
+
+	// Optional: The propagator used to send the trace context to the server. Default is the global TextMapPropagator.
+	Propagator propagation.TextMapPropagator
+}
+
+var _ client.Tracer = (*Tracer)(nil)
+
+// New returns a Tracer which uses the global TracerProvider and TextMapPropagator.
+func New() *Tracer {
+	return &Tracer{}
+}
+
+// StartFetch starts a span for a fetch and injects its trace context into the request headers.
+func (t *Tracer) StartFetch(req *http.Request) (*http.Request, func(info client.FetchInfo)) {
+	tp := t.TracerProvider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	prop := t.Propagator
+	if prop == nil {
+		prop = otel.GetTextMapPropagator()
+	}
+
+	tracer := tp.Tracer(instrumentationName)
+	ctx, span := tracer.Start(req.Context(), "viteset.fetch", trace.WithSpanKind(trace.SpanKindClient))
+	req = req.WithContext(ctx)
+	prop.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	return req, func(info client.FetchInfo) {
+		span.SetAttributes(
+			attribute.String("viteset.blob", info.Blob),
+			attribute.Int("http.status_code", info.StatusCode),
+			attribute.Bool("viteset.cache_hit", info.NotModified),
+			attribute.Int64("viteset.duration_ms", info.Duration.Milliseconds()),
+		)
+		if info.Error != nil {
+			span.RecordError(info.Error)
+			span.SetStatus(codes.Error, info.Error.Error())
+		}
+		span.End()
+	}
+}
@@ -0,0 +1,54 @@
+package vitesetotel_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	client "github.com/mplewis/viteset-client-go"
+	"github.com/mplewis/viteset-client-go/vitesetotel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracer(t *testing.T) {
+	traceparents := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents <- r.Header.Get("Traceparent")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(srv.Close)
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	c := &client.Client{Blob: "some-blob", Secret: "some-secret", Host: srv.URL,
+		Tracer: &vitesetotel.Tracer{TracerProvider: tp, Propagator: propagation.TraceContext{}}}
+	if _, err := c.Get(); err == nil {
+		t.Fatal("expected an error for a missing blob")
+	}
+	if traceparent := <-traceparents; traceparent == "" {
+		t.Error("expected the trace context to be propagated to the server")
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "viteset.fetch" {
+		t.Errorf("expected a viteset.fetch span, got %s", span.Name)
+	}
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	if attrs["viteset.blob"].AsString() != "some-blob" || attrs["http.status_code"].AsInt64() != 404 {
+		t.Errorf("unexpected span attributes: %v", span.Attributes)
+	}
+	if span.Status.Code != codes.Error {
+		t.Errorf("expected an error status for the failed fetch, got %v", span.Status)
+	}
+}
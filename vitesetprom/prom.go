@@ -0,0 +1,82 @@
+// Package vitesetprom exports Viteset client stats as Prometheus metrics.
+//
+// Wrap a Client in a Collector and register it:
+//
+//     c := &client.Client{
+//         Blob:   "SOME_BLOB_NAME",
+//         Secret: "SOME_CLIENT_SECRET",
+//     }
+//     if err := vitesetprom.New(c).RegisterWith(prometheus.DefaultRegisterer); err != nil {
+//         log.Panic(err)
+//     }
+//
+// Each metric has a blob label, so Collectors for several Clients can share a registry.
+//
+// vitesetprom is a separate module, so the core client doesn't depend on Prometheus.
+package vitesetprom
+
+import (
+	"time"
+
+	client "github.com/mplewis/viteset-client-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	fetchesDesc = prometheus.NewDesc("viteset_fetches_total",
+		"Fetches attempted, whether they succeeded or failed.", []string{"blob"}, nil)
+	errorsDesc = prometheus.NewDesc("viteset_fetch_errors_total",
+		"Fetches which failed.", []string{"blob"}, nil)
+	notModifiedDesc = prometheus.NewDesc("viteset_fetch_not_modified_total",
+		"Fetches which found the blob unchanged, served from the cache.", []string{"blob"}, nil)
+	bytesDesc = prometheus.NewDesc("viteset_fetch_bytes_total",
+		"Total size of the response bodies read, in bytes.", []string{"blob"}, nil)
+	lastSuccessAgeDesc = prometheus.NewDesc("viteset_last_success_age_seconds",
+		"Time since the last successful fetch.", []string{"blob"}, nil)
+	valueSizeDesc = prometheus.NewDesc("viteset_value_bytes",
+		"Size of the current value of the blob, in bytes.", []string{"blob"}, nil)
+)
+
+// Collector implements prometheus.Collector with a Client's Stats.
+type Collector struct {
+	c *client.Client
+}
+
+var _ prometheus.Collector = (*Collector)(nil)
+
+// New returns a Collector for c's metrics.
+func New(c *client.Client) *Collector {
+	return &Collector{c: c}
+}
+
+// RegisterWith registers the Collector with reg.
+func (col *Collector) RegisterWith(reg prometheus.Registerer) error {
+	return reg.Register(col)
+}
+
+// Describe sends the descriptors of the Collector's metrics.
+func (col *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		fetchesDesc, errorsDesc, notModifiedDesc, bytesDesc, lastSuccessAgeDesc, valueSizeDesc,
+	} {
+		ch <- desc
+	}
+}
+
+// Collect sends the current values of the Collector's metrics. The gauges are omitted until the Client has
+// fetched successfully, or has a value.
+func (col *Collector) Collect(ch chan<- prometheus.Metric) {
+	blob := col.c.Blob
+	stats := col.c.Stats()
+	ch <- prometheus.MustNewConstMetric(fetchesDesc, prometheus.CounterValue, float64(stats.Fetches), blob)
+	ch <- prometheus.MustNewConstMetric(errorsDesc, prometheus.CounterValue, float64(stats.Errors), blob)
+	ch <- prometheus.MustNewConstMetric(notModifiedDesc, prometheus.CounterValue, float64(stats.NotModified), blob)
+	ch <- prometheus.MustNewConstMetric(bytesDesc, prometheus.CounterValue, float64(stats.Bytes), blob)
+	if t, ok := col.c.LastSuccess(); ok {
+		age := time.Since(t).Seconds()
+		ch <- prometheus.MustNewConstMetric(lastSuccessAgeDesc, prometheus.GaugeValue, age, blob)
+	}
+	if value, ok := col.c.Value(); ok {
+		ch <- prometheus.MustNewConstMetric(valueSizeDesc, prometheus.GaugeValue, float64(len(value)), blob)
+	}
+}
@@ -0,0 +1,51 @@
+package vitesetprom_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	client "github.com/mplewis/viteset-client-go"
+	"github.com/mplewis/viteset-client-go/vitesetprom"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	t.Cleanup(srv.Close)
+	c := &client.Client{Blob: "some-blob", Secret: "some-secret", Host: srv.URL}
+	if _, err := c.WaitForValue(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	col := vitesetprom.New(c)
+	if err := col.RegisterWith(prometheus.NewRegistry()); err != nil {
+		t.Fatal(err)
+	}
+	want := `
+# HELP viteset_fetches_total Fetches attempted, whether they succeeded or failed.
+# TYPE viteset_fetches_total counter
+viteset_fetches_total{blob="some-blob"} 1
+# HELP viteset_fetch_errors_total Fetches which failed.
+# TYPE viteset_fetch_errors_total counter
+viteset_fetch_errors_total{blob="some-blob"} 0
+# HELP viteset_fetch_bytes_total Total size of the response bodies read, in bytes.
+# TYPE viteset_fetch_bytes_total counter
+viteset_fetch_bytes_total{blob="some-blob"} 5
+# HELP viteset_value_bytes Size of the current value of the blob, in bytes.
+# TYPE viteset_value_bytes gauge
+viteset_value_bytes{blob="some-blob"} 5
+`
+	if err := testutil.CollectAndCompare(col, strings.NewReader(want), "viteset_fetches_total",
+		"viteset_fetch_errors_total", "viteset_fetch_bytes_total", "viteset_value_bytes"); err != nil {
+		t.Error(err)
+	}
+	if n := testutil.CollectAndCount(col, "viteset_last_success_age_seconds"); n != 1 {
+		t.Errorf("expected a last success age after a successful fetch, got %d metrics", n)
+	}
+}
@@ -0,0 +1,115 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+)
+
+// Set replaces the value of the blob. The Client's Secret must have write access to the blob.
+//
+// If the server rejects the write, the error is one of the typed errors returned by fetches, such as AuthError.
+func (c *Client) Set(value []byte) error {
+	return c.SetContext(c.baseContext(), value)
+}
+
+// SetContext works like Set, but aborts the request if ctx is canceled.
+func (c *Client) SetContext(ctx context.Context, value []byte) error {
+	if err := c.setup(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	req, err := c.newRequest(ctx, c.currentHost(), http.MethodPut, bytes.NewReader(value))
+	if err != nil {
+		return err
+	}
+	_, err = c.write(req)
+	return err
+}
+
+// SetIfUnchanged replaces the value of the blob only if it hasn't changed since the Client last fetched it, for safe
+// read-modify-write updates of a blob edited by more than one writer. It sends the Client's current ETag in an
+// If-Match header. If the blob has changed, it returns a ConflictError.
+//
+// The Client must have an ETag for the blob first, from a subscription, WaitForValue, or InitialETag. Get doesn't
+// record one. If the server responds with the new value's ETag, the Client caches the written value with it, so
+// the next SetIfUnchanged follows on from this write. Subscribers don't receive an Update for the written value.
+func (c *Client) SetIfUnchanged(value []byte) error {
+	return c.SetIfUnchangedContext(c.baseContext(), value)
+}
+
+// SetIfUnchangedContext works like SetIfUnchanged, but aborts the request if ctx is canceled.
+func (c *Client) SetIfUnchangedContext(ctx context.Context, value []byte) error {
+	if err := c.setup(); err != nil {
+		return err
+	}
+	etag := c.ETag()
+	if etag == "" {
+		return errors.New("no ETag for the blob, fetch it before calling SetIfUnchanged")
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	req, err := c.newRequest(ctx, c.currentHost(), http.MethodPut, bytes.NewReader(value))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("If-Match", etag)
+	header, err := c.write(req)
+	if err != nil {
+		return err
+	}
+	if etag := header.Get("ETag"); etag != "" {
+		// the cache now holds what we wrote, so the next SetIfUnchanged can follow on from it
+		c.store(value, version{etag: etag})
+	}
+	return nil
+}
+
+// Delete deletes the blob. The Client's Secret must have write access to the blob.
+//
+// If the blob doesn't exist, Delete returns a NotFoundError, unless DeleteIgnoreNotFound is set.
+func (c *Client) Delete() error {
+	return c.DeleteContext(c.baseContext())
+}
+
+// DeleteContext works like Delete, but aborts the request if ctx is canceled.
+func (c *Client) DeleteContext(ctx context.Context) error {
+	if err := c.setup(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	req, err := c.newRequest(ctx, c.currentHost(), http.MethodDelete, nil)
+	if err != nil {
+		return err
+	}
+	_, err = c.write(req)
+	var nf *NotFoundError
+	if c.DeleteIgnoreNotFound && errors.As(err, &nf) {
+		return nil
+	}
+	return err
+}
+
+// write sends a request which modifies the blob, returning the response headers, or an error unless the server
+// responds with a 2xx status.
+func (c *Client) write(req *http.Request) (http.Header, error) {
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, &NetworkError{Err: err}
+	}
+	defer closeBody(resp)
+	body, err := c.readBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, newStatusError(resp, body, c.now())
+	}
+	return resp.Header, nil
+}